@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+// annotationComment 生成写在节点上方的测试结果注释
+func annotationComment(result Result, testedAt string) string {
+	if result.Bandwidth <= 0 {
+		return fmt.Sprintf("bandwidth: N/A, ttfb: N/A, tested: %s", testedAt)
+	}
+	return fmt.Sprintf("bandwidth: %.1fMbps, ttfb: %dms, tested: %s",
+		result.Bandwidth*8/1000/1000, result.TTFB.Milliseconds(), testedAt)
+}
+
+// annotateProxies 为 proxies 序列中测试过的节点添加测试结果注释
+func annotateProxies(seq *yaml.Node, results []Result) {
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return
+	}
+	tested := make(map[string]Result, len(results))
+	for _, result := range results {
+		tested[result.Name] = result
+	}
+	testedAt := time.Now().Format("2006-01-02")
+	for _, item := range seq.Content {
+		name := mappingValue(item, "name")
+		if name == nil {
+			continue
+		}
+		if result, ok := tested[name.Value]; ok {
+			item.HeadComment = annotationComment(result, testedAt)
+		}
+	}
+}
+
+// marshalProxies 序列化节点配置，开启 -annotate 时在节点上方写入测试结果注释
+func marshalProxies(v any, results []Result) ([]byte, error) {
+	if !*annotate {
+		return yaml.Marshal(v)
+	}
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.MappingNode {
+		annotateProxies(mappingValue(&node, "proxies"), results)
+	} else {
+		annotateProxies(&node, results)
+	}
+	return yaml.Marshal(&node)
+}
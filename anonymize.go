@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// anonymizedNames 记录 原名->匿名 的映射，-keep-config 时用于更新分组中的节点名
+var anonymizedNames map[string]string
+
+// anonymizedSources 记录 配置来源->匿名 的映射
+var anonymizedSources map[string]string
+
+// anonymizeKeySize 为 hash 模式的 HMAC 密钥长度
+const anonymizeKeySize = 32
+
+// loadAnonymizeKey 读取保存在映射文件旁的 HMAC 密钥，不存在时随机生成并保存，
+// 同一密钥下节点每次得到相同的匿名名，没有密钥的人无法用字典反推节点名
+func loadAnonymizeKey(path string) ([]byte, error) {
+	if buf, err := os.ReadFile(path); err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+		if err != nil || len(key) != anonymizeKeySize {
+			return nil, fmt.Errorf("invalid anonymize key %s", path)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, anonymizeKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("save anonymize key: %w", err)
+	}
+	return key, nil
+}
+
+// anonymousName 生成匿名节点名，hash 模式下使用 key 计算 HMAC，同一节点每次运行得到的名字相同
+func anonymousName(mode string, key []byte, name string, index int) (string, error) {
+	switch mode {
+	case "hash":
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(name))
+		return "node-" + hex.EncodeToString(mac.Sum(nil)[:4]), nil
+	case "seq":
+		return fmt.Sprintf("node-%03d", index), nil
+	default:
+		return "", fmt.Errorf("unsupported anonymize mode: %s", mode)
+	}
+}
+
+// anonymizeProxies 将测试结果和节点配置中的节点名替换为匿名名，hash 模式的密钥保存在 keyPath
+func anonymizeProxies(mode string, keyPath string, results []Result, proxies map[string]CProxy) error {
+	names := make([]string, 0, len(proxies))
+	seen := make(map[string]bool, len(proxies))
+	for _, result := range results {
+		names = append(names, result.Name)
+		seen[result.Name] = true
+	}
+	var rest []string
+	for name := range proxies {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+
+	var key []byte
+	if mode == "hash" {
+		var err error
+		if key, err = loadAnonymizeKey(keyPath); err != nil {
+			return err
+		}
+	}
+	anonymizedNames = make(map[string]string, len(names))
+	for i, name := range names {
+		alias, err := anonymousName(mode, key, name, i+1)
+		if err != nil {
+			return err
+		}
+		anonymizedNames[name] = alias
+	}
+	dedupAnonymizedNames()
+
+	for i := range results {
+		results[i].Name = anonymizedNames[results[i].Name]
+	}
+	renamed := make(map[string]CProxy, len(proxies))
+	for name, proxy := range proxies {
+		if config, ok := proxy.SecretConfig.(map[string]any); ok {
+			proxy.SecretConfig = renamedConfig(config, anonymizedNames[name])
+		}
+		renamed[anonymizedNames[name]] = proxy
+		delete(proxies, name)
+	}
+	for name, proxy := range renamed {
+		proxies[name] = proxy
+	}
+	return nil
+}
+
+// dedupAnonymizedNames 处理哈希截断后的冲突：冲突的节点按原名排序，第一个保留匿名名，其余依次加上 -2、-3 等后缀
+func dedupAnonymizedNames() {
+	groups := make(map[string][]string)
+	for name, alias := range anonymizedNames {
+		groups[alias] = append(groups[alias], name)
+	}
+	aliases := make([]string, 0, len(groups))
+	for alias, names := range groups {
+		if len(names) > 1 {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		names := groups[alias]
+		sort.Strings(names)
+		suffix := 2
+		for _, name := range names[1:] {
+			for {
+				candidate := fmt.Sprintf("%s-%d", alias, suffix)
+				suffix++
+				if _, taken := groups[candidate]; !taken {
+					groups[candidate] = []string{name}
+					anonymizedNames[name] = candidate
+					break
+				}
+			}
+		}
+	}
+}
+
+// anonymizeSources 将结果中的配置来源替换为 source-1 这样的编号，编号为配置在 -c 中的顺序，
+// 避免 -show-source 和汇总输出订阅的域名；对应关系同样写入映射文件
+func anonymizeSources(results []Result, configPaths []string) {
+	anonymizedSources = make(map[string]string, len(configPaths))
+	for i, configPath := range configPaths {
+		if _, ok := anonymizedSources[configPath]; !ok {
+			anonymizedSources[configPath] = fmt.Sprintf("source-%d", i+1)
+		}
+	}
+	for i := range results {
+		if alias, ok := anonymizedSources[results[i].Source]; ok {
+			results[i].Source = alias
+		}
+	}
+}
+
+// writeAnonymizeMap 输出 匿名->原名 的映射文件，包括节点和配置来源，仅供本地查询，不要随结果公开
+func writeAnonymizeMap(filePath string) error {
+	fp, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	names := make([]string, 0, len(anonymizedNames))
+	for name := range anonymizedNames {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return anonymizedNames[names[i]] < anonymizedNames[names[j]]
+	})
+
+	if _, err := fp.WriteString("\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(fp)
+	if err := writer.Write([]string{"匿名", "节点"}); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writer.Write([]string{anonymizedNames[name], name}); err != nil {
+			return err
+		}
+	}
+	sources := make([]string, 0, len(anonymizedSources))
+	for source := range anonymizedSources {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return anonymizedSources[sources[i]] < anonymizedSources[sources[j]]
+	})
+	for _, source := range sources {
+		if err := writer.Write([]string{anonymizedSources[source], source}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
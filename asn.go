@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+	"os"
+)
+
+const asnDownloadURL = "https://github.com/P3TERX/GeoLite.mmdb/raw/download/GeoLite2-ASN.mmdb"
+
+type ASNDB struct {
+	reader *maxminddb.Reader
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// loadASN 加载 GeoLite2-ASN 数据库，文件不存在时从 GeoLite 镜像下载
+func loadASN(path string) (*ASNDB, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := downloadMMDB(asnDownloadURL, path); err != nil {
+			return nil, fmt.Errorf("download asn database: %w", err)
+		}
+	}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ASNDB{reader: reader}, nil
+}
+
+// Lookup 返回 IP 所属的 ASN 和运营商，如 AS13335 Cloudflare
+func (a *ASNDB) Lookup(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	var record asnRecord
+	if err := a.reader.Lookup(ip, &record); err != nil || record.Number == 0 {
+		return ""
+	}
+	return fmt.Sprintf("AS%d %s", record.Number, record.Organization)
+}
+
+func formatASN(asn string) string {
+	if asn == "" {
+		return "N/A"
+	}
+	return asn
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploadURL 为空时不测试上传，由 -backend 设置
+var uploadURL string
+
+// setupBackend 根据 -backend 设置下载和上传的测试地址
+func setupBackend(backend string, server string) error {
+	switch backend {
+	case "":
+	case "librespeed":
+		if server == "" {
+			return fmt.Errorf("librespeed backend requires -server")
+		}
+		if flagPassed("l") {
+			return fmt.Errorf("-l can not be used with librespeed backend, which downloads from -server")
+		}
+		server = strings.TrimSuffix(server, "/")
+		*livenessObject = server + "/backend/garbage.php?ckSize=%d"
+		uploadURL = server + "/backend/empty.php"
+	case "ookla":
+	default:
+		return fmt.Errorf("unsupported backend: %s", backend)
+	}
+	return nil
+}
+
+// flagPassed 判断命令行中是否显式指定了该参数
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+func uploadEnabled() bool {
+	return uploadURL != "" || *backend == "ookla"
+}
+
+// ooklaServersURL 返回离请求来源最近的 speedtest.net 服务器，按距离排序
+const ooklaServersURL = "https://www.speedtest.net/api/js/servers?engine=js&https_functional=true&limit=5"
+
+type ooklaServer struct {
+	URL      string `json:"url"` // 上传地址，如 http://host:8080/speedtest/upload.php
+	Host     string `json:"host"`
+	Name     string `json:"name"`
+	Country  string `json:"country"`
+	Sponsor  string `json:"sponsor"`
+	Distance int    `json:"distance"`
+}
+
+// TestOokla 通过节点获取离出口最近的 speedtest.net 服务器，并对其测试下载和上传
+func TestOokla(name string, proxy C.Proxy, downloadSize int, timeout time.Duration, concurrentCount int) *Result {
+	var servers []ooklaServer
+	if err := getJSON(newProxyClient(proxy, timeout), ooklaServersURL, &servers); err != nil || len(servers) == 0 {
+		return &Result{Name: name, Bandwidth: -1, TTFB: -1}
+	}
+	server := servers[0]
+	result := TestProxyConcurrent(name, proxy, "https://"+server.Host+"/download?size=%d", downloadSize, timeout, concurrentCount)
+	result.Server = fmt.Sprintf("%s - %s (%dkm)", server.Sponsor, server.Name, server.Distance)
+	if result.Bandwidth > 0 {
+		endpoint := server.URL
+		if endpoint == "" {
+			endpoint = "https://" + server.Host + "/upload"
+		}
+		result.Upload = TestUpload(proxy, endpoint, downloadSize, timeout)
+	}
+	return result
+}
+
+// formatLivenessURL 填充测试地址中的下载大小，LibreSpeed 的 garbage.php 以 MB 为单位；
+// 地址中没有 %d 时原样返回，由 applySizeRange 限制下载大小
+func formatLivenessURL(livenessURL string, size int) string {
+	if !hasSizePlaceholder(livenessURL) {
+		return livenessURL
+	}
+	if strings.Contains(livenessURL, "garbage.php") {
+		mb := size / 1024 / 1024
+		if mb < 1 {
+			mb = 1
+		}
+		return fmt.Sprintf(livenessURL, mb)
+	}
+	return fmt.Sprintf(livenessURL, size)
+}
+
+// hasSizePlaceholder 判断测试地址是否包含下载大小占位符
+func hasSizePlaceholder(livenessURL string) bool {
+	return strings.Contains(livenessURL, "%d")
+}
+
+// applySizeRange 对没有占位符的静态文件地址发送 Range 请求，只下载前 size 字节
+func applySizeRange(req *http.Request, livenessURL string, size int) {
+	if !hasSizePlaceholder(livenessURL) && req.Header.Get("Range") == "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+	}
+}
+
+// limitSizeBody 限制静态文件的读取量，服务器忽略 Range 返回整个文件时也只读 size 字节
+func limitSizeBody(body io.Reader, livenessURL string, size int) io.Reader {
+	if hasSizePlaceholder(livenessURL) {
+		return body
+	}
+	return io.LimitReader(body, int64(size))
+}
+
+// zeroReader 无限输出零字节，作为 AES-CTR 密钥流的明文
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// randomPayload 生成 size 字节无法压缩的随机数据流，避免中转节点透明压缩虚高上传速度；
+// 用随机密钥的 AES-CTR 密钥流代替逐字节读取 crypto/rand，生成速度不会成为瓶颈
+func randomPayload(size int) (io.Reader, error) {
+	key := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.StreamReader{S: cipher.NewCTR(block, iv), R: zeroReader{}}
+	return io.LimitReader(stream, int64(size)), nil
+}
+
+// uploadCheckpoint 为上传过程中某一时刻已发送的字节数
+type uploadCheckpoint struct {
+	at   time.Time
+	sent int64
+}
+
+// uploadReader 记录请求体的发送进度，stall 不为 nil 时每次读取都重置停滞计时；
+// 超时后 transport 可能仍在读取，因此加锁
+type uploadReader struct {
+	r           io.Reader
+	stall       *time.Timer
+	mu          sync.Mutex
+	sent        int64
+	checkpoints []uploadCheckpoint
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.mu.Lock()
+	r.sent += int64(n)
+	now := time.Now()
+	if len(r.checkpoints) == 0 || now.Sub(r.checkpoints[len(r.checkpoints)-1].at) >= 100*time.Millisecond {
+		r.checkpoints = append(r.checkpoints, uploadCheckpoint{at: now, sent: r.sent})
+	}
+	r.mu.Unlock()
+	if r.stall != nil {
+		r.stall.Reset(stallTimeout)
+	}
+	return n, err
+}
+
+// partialRate 计算中止的上传的速度：开始时数据先填满本地和节点的缓冲区，读取速度远高于实际上传速度，
+// 因此只用后一半时间内发送的字节数计算，没有发送数据时返回 -1
+func (r *uploadReader) partialRate(start time.Time, end time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent == 0 {
+		return -1
+	}
+	from := uploadCheckpoint{at: start}
+	half := start.Add(end.Sub(start) / 2)
+	for _, checkpoint := range r.checkpoints {
+		if !checkpoint.at.Before(half) {
+			break
+		}
+		from = checkpoint
+	}
+	if r.sent <= from.sent || !end.After(from.at) {
+		return -1
+	}
+	return float64(r.sent-from.sent) / end.Sub(from.at).Seconds()
+}
+
+// TestUpload 通过节点上传 uploadSize 字节，返回上传带宽；与下载测试一样使用分阶段超时，
+// 上传中途超时时按超时前已发送的字节数计算带宽，没有发送任何数据时返回 -1
+func TestUpload(proxy C.Proxy, endpoint string, uploadSize int, timeout time.Duration) float64 {
+	client := newDownloadClient(proxy, timeout)
+	defer client.CloseIdleConnections()
+	payload, err := randomPayload(uploadSize)
+	if err != nil {
+		return -1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := &uploadReader{r: payload}
+	if stallTimeout > 0 {
+		body.stall = time.AfterFunc(stallTimeout, cancel)
+		defer body.stall.Stop()
+	}
+	var reader io.Reader = body
+	if testLimiter != nil {
+		reader = &limitedReader{ctx: ctx, r: reader}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, reader)
+	if err != nil {
+		return -1
+	}
+	req.ContentLength = int64(uploadSize)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	applyTestHeaders(req)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		// 超时或停滞中止时按已发送的部分计算
+		return body.partialRate(start, start.Add(elapsed))
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return -1
+	}
+	return float64(uploadSize) / elapsed.Seconds()
+}
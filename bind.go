@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Dreamacro/clash/component/dialer"
+	"net"
+	"strings"
+)
+
+// bindDialer 使用指定的源地址连接节点，UDP 协议的节点同样适用
+type bindDialer struct {
+	ip net.IP
+}
+
+func (d bindDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var local net.Addr = &net.TCPAddr{IP: d.ip}
+	if strings.HasPrefix(network, "udp") {
+		local = &net.UDPAddr{IP: d.ip}
+	}
+	return (&net.Dialer{LocalAddr: local}).DialContext(ctx, network, address)
+}
+
+// applyBindOptions 让连接节点的流量从指定的网卡或源地址发出，两者只能指定一个
+func applyBindOptions(iface string, bindAddress string) error {
+	if iface != "" && bindAddress != "" {
+		return fmt.Errorf("-interface and -bind-address can not be used together")
+	}
+	if iface != "" {
+		if _, err := net.InterfaceByName(iface); err != nil {
+			return fmt.Errorf("interface %s: %w", iface, err)
+		}
+		dialer.DefaultInterface.Store(iface)
+	}
+	if bindAddress != "" {
+		ip := net.ParseIP(bindAddress)
+		if ip == nil {
+			return fmt.Errorf("invalid bind address %s", bindAddress)
+		}
+		dialer.DefaultOptions = append(dialer.DefaultOptions, dialer.WithNetDialer(bindDialer{ip: ip}))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type blacklistEntry struct {
+	Name        string    `json:"name"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// Blacklist 记录连续测试失败的节点，以节点配置指纹为 key，节点改名后依然有效
+type Blacklist struct {
+	path    string
+	Entries map[string]*blacklistEntry `json:"entries"`
+}
+
+// parseDuration 在 time.ParseDuration 的基础上支持以天为单位，如 7d
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func loadBlacklist(path string) (*Blacklist, error) {
+	blacklist := &Blacklist{path: path, Entries: make(map[string]*blacklistEntry)}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return blacklist, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, blacklist); err != nil {
+		return nil, err
+	}
+	if blacklist.Entries == nil {
+		blacklist.Entries = make(map[string]*blacklistEntry)
+	}
+	return blacklist, nil
+}
+
+// blacklistKey 优先使用配置指纹，provider 中没有原始配置的节点退回使用节点名
+func blacklistKey(name string, proxy CProxy) string {
+	if fingerprint := configFingerprint(proxy.SecretConfig); fingerprint != "" {
+		return fingerprint
+	}
+	return "name:" + name
+}
+
+// Blocked 判断节点是否已连续失败 threshold 次，且距上次失败未超过 forgive
+func (b *Blacklist) Blocked(key string, threshold int, forgive time.Duration) bool {
+	entry, ok := b.Entries[key]
+	if !ok || entry.Failures < threshold {
+		return false
+	}
+	return time.Since(entry.LastFailure) < forgive
+}
+
+// Record 根据本次测试结果更新失败次数，测试成功的节点移出黑名单
+func (b *Blacklist) Record(key string, result Result) {
+	if result.Bandwidth > 0 {
+		delete(b.Entries, key)
+		return
+	}
+	entry, ok := b.Entries[key]
+	if !ok {
+		entry = &blacklistEntry{}
+		b.Entries[key] = entry
+	}
+	entry.Name = result.Name
+	entry.Failures++
+	entry.LastFailure = time.Now()
+}
+
+func (b *Blacklist) Save() error {
+	buf, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, buf, 0o644)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "7d", want: 7 * 24 * time.Hour},
+		{in: "0.5d", want: 12 * time.Hour},
+		{in: "6h", want: 6 * time.Hour},
+		{in: "xd", wantErr: true},
+		{in: "6", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBlacklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.json")
+	blacklist, err := loadBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, passed := Result{Name: "a"}, Result{Name: "a", Bandwidth: 1}
+	blacklist.Record("key", failed)
+	blacklist.Record("key", failed)
+	if blacklist.Blocked("key", 3, time.Hour) {
+		t.Error("blocked before reaching the threshold")
+	}
+	blacklist.Record("key", failed)
+	if !blacklist.Blocked("key", 3, time.Hour) {
+		t.Error("not blocked after reaching the threshold")
+	}
+	if blacklist.Blocked("key", 3, 0) {
+		t.Error("still blocked after the forgive duration")
+	}
+	if blacklist.Blocked("other", 3, time.Hour) {
+		t.Error("unknown key should not be blocked")
+	}
+
+	if err := blacklist.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := loaded.Entries["key"]; entry == nil || entry.Failures != 3 || entry.Name != "a" {
+		t.Errorf("loaded entry = %+v, want 3 failures of a", entry)
+	}
+
+	loaded.Record("key", passed)
+	if loaded.Blocked("key", 1, time.Hour) {
+		t.Error("still blocked after a successful test")
+	}
+}
+
+func TestBlacklistKey(t *testing.T) {
+	config := map[string]any{"name": "a", "type": "ss", "server": "1.2.3.4", "port": 8388}
+	if got := blacklistKey("a", CProxy{SecretConfig: config}); got != configFingerprint(config) {
+		t.Errorf("blacklistKey() = %q, want the config fingerprint", got)
+	}
+	if got := blacklistKey("a", CProxy{}); got != "name:a" {
+		t.Errorf("blacklistKey() = %q, want name:a", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+)
+
+// resultCache 为 nil 时不使用缓存
+var resultCache *ResultCache
+
+type cacheEntry struct {
+	Result   Result    `json:"result"`
+	TestedAt time.Time `json:"tested_at"`
+}
+
+// ResultCache 缓存测试结果，以节点配置指纹为 key，有效期内不再重复测试
+type ResultCache struct {
+	path       string
+	ttl        time.Duration
+	keepFailed bool                  // 同时缓存失败的结果，只用于一次运行内的复用
+	Entries    map[string]cacheEntry `json:"entries"`
+}
+
+func loadResultCache(path string, ttl time.Duration) (*ResultCache, error) {
+	cache := &ResultCache{path: path, ttl: ttl, Entries: make(map[string]cacheEntry)}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, cache); err != nil {
+		return nil, err
+	}
+	if cache.Entries == nil {
+		cache.Entries = make(map[string]cacheEntry)
+	}
+	// 顺便清理过期的结果，避免缓存文件无限增长
+	for key, entry := range cache.Entries {
+		if time.Since(entry.TestedAt) >= ttl {
+			delete(cache.Entries, key)
+		}
+	}
+	return cache, nil
+}
+
+// Get 返回有效期内的缓存结果，节点名替换为当前的节点名
+func (c *ResultCache) Get(key string, name string) (*Result, bool) {
+	entry, ok := c.Entries[key]
+	if !ok || key == "" || time.Since(entry.TestedAt) >= c.ttl {
+		return nil, false
+	}
+	result := entry.Result
+	result.Name = name
+	return &result, true
+}
+
+// resultCacheFlags 为会改变测试结果的参数，包括下载方式和各项附加测试
+var resultCacheFlags = []string{
+	"size", "l", "backend", "server", "concurrent", "limit", "max-node-data", "conn-mode", "http-version", "test-method",
+	"accept-status", "verify-payload", "ipv6", "colo", "targets", "check-cert", "cert-pin", "tls-info", "check-compression",
+	"http3", "latency-url", "server-rtt", "ping", "dns-time", "check-udp", "reach-check", "geoip", "geoip-source", "asn",
+	"ip-risk", "dedup",
+}
+
+// resultCacheKey 由节点配置指纹和影响测试结果的参数组成，参数不同时不复用之前测得的结果
+func resultCacheKey(config any) string {
+	fingerprint := configFingerprint(config)
+	if fingerprint == "" {
+		return ""
+	}
+	params := make([]string, 0, len(resultCacheFlags))
+	for _, name := range resultCacheFlags {
+		params = append(params, name+"="+flag.Lookup(name).Value.String())
+	}
+	sum := sha256.Sum256([]byte(strings.Join(params, "|")))
+	return fingerprint + "-" + hex.EncodeToString(sum[:8])
+}
+
+// Put 缓存测试结果，失败的结果可能只是偶发的网络问题，默认不缓存，以免节点在有效期内一直显示为不可用
+func (c *ResultCache) Put(key string, result Result) {
+	if key == "" || (result.Bandwidth <= 0 && !c.keepFailed) {
+		return
+	}
+	c.Entries[key] = cacheEntry{Result: result, TestedAt: time.Now()}
+}
+
+func (c *ResultCache) Save() error {
+	// -watch 模式下未指定 -cache 时只保存在内存中
+	if c.path == "" {
+		return nil
+	}
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, buf, 0o644)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"testing"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	config := map[string]any{"name": "a", "type": "ss", "server": "1.2.3.4", "port": 443, "cipher": "aes-128-gcm", "password": "x"}
+	cache := &ResultCache{ttl: math.MaxInt64, Entries: make(map[string]cacheEntry)}
+	cache.Put(resultCacheKey(config), Result{Name: "a", Bandwidth: 1024})
+	if _, ok := cache.Get(resultCacheKey(config), "a"); !ok {
+		t.Fatal("cache miss with unchanged flags")
+	}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "check-cert", value: "true"},
+		{name: "tls-info", value: "true"},
+		{name: "check-compression", value: "true"},
+		{name: "http3", value: "true"},
+		{name: "check-udp", value: "true"},
+		{name: "dns-time", value: "true"},
+		{name: "ping", value: "3"},
+		{name: "geoip", value: "builtin"},
+		{name: "geoip-source", value: "exit"},
+		{name: "asn", value: "asn.mmdb"},
+		{name: "ip-risk", value: "ip-api"},
+		{name: "dedup", value: "exit-ip"},
+		{name: "reach-check", value: "github.com"},
+		{name: "size", value: "10"},
+	}
+	for _, tt := range tests {
+		f := flag.Lookup(tt.name)
+		if err := flag.Set(tt.name, tt.value); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cache.Get(resultCacheKey(config), "a"); ok {
+			t.Errorf("cache hit after -%s=%s", tt.name, tt.value)
+		}
+		if err := flag.Set(tt.name, f.DefValue); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
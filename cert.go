@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// certPins 为 -cert-pin 指定的证书公钥指纹，即 SubjectPublicKeyInfo 的 sha256，base64 编码
+var certPins []string
+
+// parseCertPins 解析逗号分隔的公钥指纹，兼容 HPKP 的 sha256/ 前缀写法
+func parseCertPins(s string) ([]string, error) {
+	var pins []string
+	for _, pin := range strings.Split(s, ",") {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		if pin == "" {
+			continue
+		}
+		if buf, err := base64.StdEncoding.DecodeString(pin); err != nil || len(buf) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %s", pin)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// probeTLS 通过节点与测试地址完成一次 TLS 握手并返回连接状态，不校验证书，由调用方检查
+func probeTLS(proxy C.Proxy, rawURL string, timeout time.Duration) (*tls.ConnectionState, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := dialProxy(ctx, proxy, net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         u.Hostname(),
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
+
+// CheckCert 检查 probeTLS 拿到的测试地址证书：证书链需要由系统信任的 CA 签发，指定 -cert-pin 时链中还需要有匹配的公钥，
+// 不符合时说明节点可能在中间劫持流量；握手失败时返回空字符串
+func CheckCert(name string, state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	certs := state.PeerCertificates
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	status := "ok"
+	if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: state.ServerName, Intermediates: intermediates}); err != nil {
+		status = "untrusted"
+	} else if len(certPins) > 0 && !matchCertPins(certs) {
+		status = "pin mismatch"
+	}
+	if status != "ok" {
+		log.Warnln("%s: unexpected certificate of %s issued by %s (%s), the proxy may intercept traffic", name, state.ServerName, certs[0].Issuer.CommonName, status)
+	}
+	return status
+}
+
+func matchCertPins(certs []*x509.Certificate) bool {
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		fingerprint := base64.StdEncoding.EncodeToString(sum[:])
+		for _, pin := range certPins {
+			if pin == fingerprint {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatCert(status string) string {
+	if status == "" {
+		return "N/A"
+	}
+	return status
+}
+
+// tlsVersionName 返回 TLS 版本的名称，Go 1.21 之前的 crypto/tls 没有 tls.VersionName
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
+
+// formatTLS 显示协商的 TLS 版本和 ALPN，如 TLS1.3 h2
+func formatTLS(r *Result) string {
+	if r.TLS == "" {
+		return "N/A"
+	}
+	return strings.TrimSpace(r.TLS + " " + r.ALPN)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Dreamacro/clash/adapter"
+	"github.com/Dreamacro/clash/adapter/outboundgroup"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/constant/provider"
+	"github.com/Dreamacro/clash/log"
+	"github.com/Dreamacro/clash/tunnel"
+)
+
+// registerDialerProxies 将节点注册到 Clash 的 tunnel 中，使节点配置中的 dialer-proxy 可以按名称找到前置节点，
+// 同名时 proxies 中的节点优先于 provider 中的节点
+func registerDialerProxies(proxies map[string]CProxy) {
+	registered := make(map[string]C.Proxy, len(proxies))
+	for _, proxy := range proxies {
+		if _, ok := registered[proxy.Name()]; ok && proxy.Provider != "" {
+			continue
+		}
+		registered[proxy.Name()] = proxy.Proxy
+	}
+	tunnel.UpdateProxies(registered, nil)
+}
+
+// applyViaProxy 让除前置节点外的全部节点都通过 via 连接，测试结果包含链路的开销；
+// 只影响测试，导出的配置仍为原始配置
+func applyViaProxy(proxies map[string]CProxy, via string) error {
+	front, ok := proxies[via]
+	if !ok {
+		return fmt.Errorf("front proxy %s not found", via)
+	}
+	for name, proxy := range proxies {
+		if name == via {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			return fmt.Errorf("proxy %s has no config to set dialer-proxy", name)
+		}
+		chained := make(map[string]any, len(config)+1)
+		for k, v := range config {
+			chained[k] = v
+		}
+		chained["dialer-proxy"] = front.Name()
+		p, err := adapter.ParseProxy(chained)
+		if err != nil {
+			return fmt.Errorf("proxy %s: %w", name, err)
+		}
+		proxy.Proxy = p
+		proxies[name] = proxy
+	}
+	return nil
+}
+
+// relayProxies 将配置中 type 为 relay 的分组解析为可测试的节点，测试结果为整条链路的带宽和延迟；
+// 链路中只能引用节点，引用了分组或不存在的节点的 relay 会被跳过
+func relayProxies(groups []map[string]any, proxies map[string]CProxy) map[string]CProxy {
+	members := make(map[string]C.Proxy, len(proxies))
+	for _, proxy := range proxies {
+		if _, ok := members[proxy.Name()]; ok && proxy.Provider != "" {
+			continue
+		}
+		members[proxy.Name()] = proxy.Proxy
+	}
+
+	relays := make(map[string]CProxy)
+	for _, config := range groups {
+		if groupType, _ := config["type"].(string); groupType != "relay" {
+			continue
+		}
+		name, _ := config["name"].(string)
+		group, err := outboundgroup.ParseProxyGroup(config, members, map[string]provider.ProxyProvider{})
+		if err != nil {
+			log.Warnln("skip relay %s: %s", name, err)
+			continue
+		}
+		relays[name] = CProxy{Proxy: adapter.NewProxy(group)}
+	}
+	return relays
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// cdnColo 从测试响应头中解析 CDN 节点所在的机房
+func cdnColo(header http.Header) string {
+	// Cloudflare: cf-ray: 8a1b2c3d4e5f6789-SJC
+	if ray := header.Get("Cf-Ray"); ray != "" {
+		if i := strings.LastIndex(ray, "-"); i >= 0 {
+			return strings.ToUpper(ray[i+1:])
+		}
+	}
+	// CloudFront: x-amz-cf-pop: SFO5-C1
+	if pop := header.Get("X-Amz-Cf-Pop"); pop != "" {
+		code, _, _ := strings.Cut(pop, "-")
+		return strings.ToUpper(strings.TrimRight(code, "0123456789"))
+	}
+	// Fastly: x-served-by: cache-sjc10234-SJC, cache-nrt-rjtf7700074-NRT
+	if servedBy := header.Get("X-Served-By"); servedBy != "" {
+		last := strings.TrimSpace(servedBy[strings.LastIndex(servedBy, ",")+1:])
+		if i := strings.LastIndex(last, "-"); i >= 0 {
+			return strings.ToUpper(last[i+1:])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"io"
+	"net/http"
+	"time"
+)
+
+// compressionProbeSize 为压缩检查每次下载的大小，只比较字节数，不需要测满 -size
+const compressionProbeSize = 1024 * 1024
+
+// compressionProbe 为一次下载的结果，Wire 为线路上收到的字节数，Body 为解压后的字节数
+type compressionProbe struct {
+	Encoding string
+	Wire     int64
+	Body     int64
+}
+
+// CheckCompression 分别以 identity 和 gzip 的 Accept-Encoding 下载测试对象并比较字节数：
+// 要求不压缩仍收到压缩数据为 injected，两次解压后大小不一致为 altered，
+// 接受 gzip 时数据被压缩为 compressed，此时测得的带宽会虚高，无法与其他节点比较
+func CheckCompression(proxy C.Proxy, livenessURL string, timeout time.Duration) string {
+	identity, err := probeCompression(proxy, livenessURL, "identity", timeout)
+	if err != nil {
+		return ""
+	}
+	compressed, err := probeCompression(proxy, livenessURL, "gzip", timeout)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case identity.Encoding != "" && identity.Encoding != "identity":
+		return "injected"
+	case compressed.Encoding != "" && compressed.Encoding != "identity" && compressed.Encoding != "gzip":
+		return "compressed"
+	case identity.Body != compressed.Body:
+		return "altered"
+	case compressed.Wire < compressed.Body*9/10:
+		return "compressed"
+	}
+	return "ok"
+}
+
+// probeCompression 以指定的 Accept-Encoding 下载测试对象，自行解压以便同时统计线路和解压后的字节数
+func probeCompression(proxy C.Proxy, livenessURL string, encoding string, timeout time.Duration) (*compressionProbe, error) {
+	client := newProxyClient(proxy, timeout)
+	client.Transport.(*http.Transport).DisableCompression = true
+
+	req, err := http.NewRequest(*testMethod, formatLivenessURL(livenessURL, compressionProbeSize), nil)
+	if err != nil {
+		return nil, err
+	}
+	applyTestHeaders(req)
+	applySizeRange(req, livenessURL, compressionProbeSize)
+	req.Header.Set("Accept-Encoding", encoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !acceptStatus(resp.StatusCode) {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	wire := &countingReader{r: resp.Body}
+	probe := &compressionProbe{Encoding: resp.Header.Get("Content-Encoding")}
+	var body io.Reader = wire
+	if probe.Encoding == "gzip" {
+		gz, err := gzip.NewReader(wire)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	probe.Body, err = io.Copy(io.Discard, limitSizeBody(body, livenessURL, compressionProbeSize))
+	if err != nil {
+		return nil, err
+	}
+	probe.Wire = wire.n
+	return probe, nil
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func formatCompression(compression string) string {
+	if compression == "" {
+		return "N/A"
+	}
+	return compression
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// controllerDelayURL 是通过 external controller 测试延迟时默认使用的地址，可以用 -latency-url 修改
+const controllerDelayURL = "https://www.gstatic.com/generate_204"
+
+// Controller 是运行中的 Clash / mihomo 的 external controller
+type Controller struct {
+	baseURL string
+	secret  string
+	client  *http.Client
+}
+
+func newController(baseURL string, secret string, timeout time.Duration) *Controller {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	return &Controller{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		// delay 接口本身会等待 timeout，这里多留一些时间
+		client: &http.Client{Timeout: timeout + 5*time.Second},
+	}
+}
+
+func (c *Controller) request(method string, path string, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if body.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, body.Message)
+		}
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// controllerProxy 是 GET /proxies 返回的节点信息，不包含节点的服务器和凭据
+type controllerProxy struct {
+	Name string   `json:"name"`
+	Type string   `json:"type"`
+	All  []string `json:"all"`
+	Now  string   `json:"now"`
+}
+
+func (c *Controller) Proxies() (map[string]controllerProxy, error) {
+	var body struct {
+		Proxies map[string]controllerProxy `json:"proxies"`
+	}
+	if err := c.request(http.MethodGet, "/proxies", nil, &body); err != nil {
+		return nil, fmt.Errorf("list proxies: %w", err)
+	}
+	return body.Proxies, nil
+}
+
+// Delay 让 Clash 通过指定节点请求 testURL，返回延迟
+func (c *Controller) Delay(name string, testURL string, timeout time.Duration) (time.Duration, error) {
+	query := url.Values{}
+	query.Set("url", testURL)
+	query.Set("timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	var body struct {
+		Delay int `json:"delay"`
+	}
+	if err := c.request(http.MethodGet, "/proxies/"+url.PathEscape(name)+"/delay?"+query.Encode(), nil, &body); err != nil {
+		return 0, err
+	}
+	return time.Duration(body.Delay) * time.Millisecond, nil
+}
+
+// Select 切换 selector 分组当前使用的节点
+func (c *Controller) Select(group string, name string) error {
+	return c.request(http.MethodPut, "/proxies/"+url.PathEscape(group), map[string]string{"name": name}, nil)
+}
+
+// HealthCheckProviders 触发全部 proxy provider 的健康检查
+func (c *Controller) HealthCheckProviders() error {
+	var body struct {
+		Providers map[string]struct {
+			VehicleType string `json:"vehicleType"`
+		} `json:"providers"`
+	}
+	if err := c.request(http.MethodGet, "/providers/proxies", nil, &body); err != nil {
+		return fmt.Errorf("list providers: %w", err)
+	}
+	for name, provider := range body.Providers {
+		// Compatible 是 Clash 为 proxies 生成的虚拟 provider
+		if provider.VehicleType == "Compatible" {
+			continue
+		}
+		if err := c.request(http.MethodGet, "/providers/proxies/"+url.PathEscape(name)+"/healthcheck", nil, nil); err != nil {
+			log.Warnln("failed to health check provider %s: %s", name, err)
+			continue
+		}
+		fmt.Printf("已触发 provider %s 的健康检查\n", name)
+	}
+	return nil
+}
+
+// applyResults 将 groups 中的 selector 分组切换到排序后最靠前的可用节点，groups 为 all 时处理全部 selector；
+// proxies 为测试的节点，为 nil 时 results 是只测试了延迟的 controller 节点
+func applyResults(controller *Controller, results []Result, proxies map[string]CProxy, groups string) error {
+	running, err := controller.Proxies()
+	if err != nil {
+		return err
+	}
+	var names []string
+	if groups == "all" {
+		for name, proxy := range running {
+			if strings.EqualFold(proxy.Type, "selector") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	} else {
+		for _, name := range strings.Split(groups, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		group, ok := running[name]
+		if !ok || !strings.EqualFold(group.Type, "selector") {
+			log.Warnln("%s is not a selector group", name)
+			continue
+		}
+		members := make(map[string]bool, len(group.All))
+		for _, member := range group.All {
+			members[member] = true
+		}
+		for _, result := range results {
+			member := result.Name
+			if proxies != nil {
+				proxy, ok := proxies[result.Name]
+				if !ok || !usableResult(result) {
+					continue
+				}
+				// provider 中的节点以 [provider] 前缀区分，Clash 中使用的是节点本身的名称
+				member = proxy.Name()
+			} else if result.TTFB <= 0 {
+				continue
+			}
+			if !members[member] {
+				continue
+			}
+			if member != group.Now {
+				if err := controller.Select(name, member); err != nil {
+					log.Warnln("failed to switch %s to %s: %s", name, member, err)
+					break
+				}
+			}
+			fmt.Printf("分组 %s 已切换到 %s\n", name, member)
+			break
+		}
+	}
+	return nil
+}
+
+// isControllerGroup 判断是否为策略组或内置策略，这些不需要测试
+func isControllerGroup(proxyType string) bool {
+	switch strings.ToLower(proxyType) {
+	case "direct", "reject", "rejectdrop", "pass", "compatible", "selector", "urltest", "fallback", "loadbalance", "relay":
+		return true
+	}
+	return false
+}
+
+// runControllerTest 从运行中的 Clash 获取节点并通过 delay 接口测试延迟；
+// controller 不会返回节点配置，因此只能测试延迟，无法测试带宽
+func runControllerTest() {
+	timeout := time.Duration(*timeoutConfig) * time.Second
+	controller := newController(*controllerURL, *controllerSecret, timeout)
+	proxies, err := controller.Proxies()
+	if err != nil {
+		log.Fatalln("Failed to load proxies from controller: %s", err)
+	}
+
+	filterRegexp := regexp.MustCompile(*filterRegexConfig)
+	var negFilterRegexp *regexp.Regexp
+	if *negFilterRegexConfig != "" {
+		negFilterRegexp = regexp.MustCompile(*negFilterRegexConfig)
+	}
+	var names []string
+	for name, proxy := range proxies {
+		if isControllerGroup(proxy.Type) || !filterRegexp.MatchString(name) {
+			continue
+		}
+		if negFilterRegexp != nil && negFilterRegexp.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = limitProxies(names, *maxNodes)
+
+	delayURL := controllerDelayURL
+	if *latencyURL != "" {
+		delayURL = *latencyURL
+	}
+	columns := latencyColumns()
+	printHeader(columns)
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		result := Result{Name: name}
+		delay, err := controller.Delay(name, delayURL, timeout)
+		if err != nil {
+			log.Warnln("failed to test %s: %s", name, err)
+		} else {
+			result.TTFB = delay
+		}
+		result.Print(columns)
+		results = append(results, result)
+	}
+	outputLatencyResults(results, "-controller")
+
+	pushResults(controller, results, nil)
+}
+
+// pushResults 根据 -apply 和 -healthcheck 将测试结果应用到运行中的 Clash，proxies 的含义同 applyResults
+func pushResults(controller *Controller, results []Result, proxies map[string]CProxy) {
+	if *applyGroups != "" {
+		if err := applyResults(controller, results, proxies, *applyGroups); err != nil {
+			log.Warnln("failed to apply results: %s", err)
+		}
+	}
+	if *healthCheck {
+		if err := controller.HealthCheckProviders(); err != nil {
+			log.Warnln("failed to health check providers: %s", err)
+		}
+	}
+}
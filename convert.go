@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/Dreamacro/clash/common/convert"
+	"regexp"
+	"unicode/utf8"
+)
+
+// decodeBase64Body 识别 base64 编码的订阅内容，兼容标准/URL 安全编码以及缺省填充的写法
+func decodeBase64Body(buf []byte) ([]byte, bool) {
+	data := bytes.Join(bytes.Fields(buf), nil)
+	if len(data) == 0 {
+		return nil, false
+	}
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		decoded, err := encoding.DecodeString(string(data))
+		if err == nil && utf8.Valid(decoded) {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+var (
+	shareLinkRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://\S+$`)
+	hy2SchemeRegex = regexp.MustCompile(`(?m)^(\s*)hy2://`)
+)
+
+// isShareLinks 判断内容是否为每行一个的分享链接列表
+func isShareLinks(buf []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(buf)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return shareLinkRegex.Match(line)
+	}
+	return false
+}
+
+// parseShareLinks 将 vmess:// vless:// trojan:// ss:// hysteria2:// tuic:// 等分享链接转换为 Clash 节点配置
+func parseShareLinks(buf []byte) ([]map[string]any, error) {
+	// hy2:// 是 hysteria2:// 的常见简写，转换器只识别完整写法
+	buf = hy2SchemeRegex.ReplaceAll(buf, []byte("${1}hysteria2://"))
+	proxies, err := convert.ConvertsV2Ray(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no supported share link found")
+	}
+	return proxies, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeBase64Body(t *testing.T) {
+	links := "ss://YWVzLTEyOC1nY206cGFzcw@1.2.3.4:8388#ss\ntrojan://pass@example.com:443#trojan"
+	tests := []struct {
+		name   string
+		body   string
+		want   string
+		wantOK bool
+	}{
+		{name: "std", body: base64.StdEncoding.EncodeToString([]byte(links)), want: links, wantOK: true},
+		{name: "raw url", body: base64.RawURLEncoding.EncodeToString([]byte(links)), want: links, wantOK: true},
+		{name: "wrapped", body: "c3M6Ly9ZV1Z6TFRFeU9DMW5ZMjA2Y0dGemN3QDEuMi4zLjQ6\nODM4OCNzcw==\n", want: "ss://YWVzLTEyOC1nY206cGFzcw@1.2.3.4:8388#ss", wantOK: true},
+		{name: "yaml", body: "proxies:\n  - name: a\n", wantOK: false},
+		{name: "empty", body: " \n", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeBase64Body([]byte(tt.body))
+			if ok != tt.wantOK || string(got) != tt.want {
+				t.Errorf("decodeBase64Body() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsShareLinks(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"\nvmess://abc\ntrojan://def", true},
+		{"hy2://pass@1.2.3.4:443", true},
+		{"proxies:\n  - {name: a}", false},
+		{"# comment\nss://abc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isShareLinks([]byte(tt.body)); got != tt.want {
+			t.Errorf("isShareLinks(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+// shareLinkFields 为比较分享链接转换结果时关心的字段，转换器会补充其他默认值
+var shareLinkFields = []string{"name", "type", "server", "port", "cipher", "password", "uuid", "sni", "servername", "network"}
+
+func pickFields(config map[string]any) map[string]string {
+	picked := make(map[string]string)
+	for _, key := range shareLinkFields {
+		if value := configString(config, key); value != "" {
+			picked[key] = value
+		}
+	}
+	return picked
+}
+
+func TestParseShareLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		links   string
+		want    []map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "ss",
+			links: "ss://YWVzLTEyOC1nY206cGFzcw@1.2.3.4:8388#ss",
+			want:  []map[string]string{{"name": "ss", "type": "ss", "server": "1.2.3.4", "port": "8388", "cipher": "aes-128-gcm", "password": "pass"}},
+		},
+		{
+			name:  "hy2 alias",
+			links: "hy2://pass@1.2.3.4:443?sni=example.com#hy2",
+			want:  []map[string]string{{"name": "hy2", "type": "hysteria2", "server": "1.2.3.4", "port": "443", "password": "pass", "sni": "example.com"}},
+		},
+		{
+			name:  "multiple",
+			links: "trojan://pass@example.com:443?sni=sni.example.com#trojan\n\nvless://uuid@example.com:443?security=tls&type=ws&path=%2Fws#vless\n",
+			want: []map[string]string{
+				{"name": "trojan", "type": "trojan", "server": "example.com", "port": "443", "password": "pass", "sni": "sni.example.com"},
+				{"name": "vless", "type": "vless", "server": "example.com", "port": "443", "uuid": "uuid", "network": "ws"},
+			},
+		},
+		{name: "unsupported", links: "unknown://abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := parseShareLinks([]byte(tt.links))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseShareLinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []map[string]string
+			for _, proxy := range proxies {
+				got = append(got, pickFields(proxy))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseShareLinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 导出为分享链接后再解析回来，关心的字段应保持不变
+func TestShareLinkRoundTrip(t *testing.T) {
+	tests := []map[string]any{
+		{"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388, "cipher": "chacha20-ietf-poly1305", "password": "pass"},
+		{"name": "trojan", "type": "trojan", "server": "example.com", "port": 443, "password": "pass", "sni": "sni.example.com", "network": "tcp"},
+		{
+			"name": "vmess", "type": "vmess", "server": "example.com", "port": 443, "uuid": "uuid", "alterId": 0, "cipher": "auto",
+			"tls": true, "servername": "sni.example.com", "network": "ws", "ws-opts": map[string]any{"path": "/ws"},
+		},
+		{"name": "hy2", "type": "hysteria2", "server": "1.2.3.4", "port": 443, "password": "pass", "sni": "example.com"},
+	}
+	for _, config := range tests {
+		name := configString(config, "name")
+		t.Run(name, func(t *testing.T) {
+			link, err := proxyToLink(config, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxies, err := parseShareLinks([]byte(link))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := pickFields(proxies[0]), pickFields(config); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip of %s = %v, want %v", link, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// dedupModes 解析 -dedup 参数，支持逗号分隔多个模式
+func dedupModes(s string) map[string]bool {
+	modes := make(map[string]bool)
+	for _, mode := range strings.Split(s, ",") {
+		if mode = strings.TrimSpace(mode); mode != "" {
+			modes[mode] = true
+		}
+	}
+	return modes
+}
+
+// dedupByExitIP 每个出口 IP 只保留带宽最高的节点，未检测到出口 IP 的节点原样保留
+func dedupByExitIP(results []Result) (kept []Result, dropped []string) {
+	best := make(map[string]int)
+	for i, result := range results {
+		if result.ExitIP == "" {
+			continue
+		}
+		if j, ok := best[result.ExitIP]; !ok || result.Bandwidth > results[j].Bandwidth {
+			best[result.ExitIP] = i
+		}
+	}
+
+	kept = make([]Result, 0, len(results))
+	for i, result := range results {
+		if result.ExitIP != "" && best[result.ExitIP] != i {
+			dropped = append(dropped, result.Name)
+			continue
+		}
+		kept = append(kept, result)
+	}
+	return kept, dropped
+}
+
+// configFingerprint 根据节点配置（除名称外的全部字段）计算指纹，用于识别不同订阅中的相同节点
+func configFingerprint(config any) string {
+	configMap, ok := config.(map[string]any)
+	if !ok {
+		return ""
+	}
+	endpoint := make(map[string]any, len(configMap))
+	for k, v := range configMap {
+		if k != "name" {
+			endpoint[k] = v
+		}
+	}
+	// encoding/json 按 key 排序输出 map，结果是稳定的
+	buf, err := json.Marshal(endpoint)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
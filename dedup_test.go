@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupModes(t *testing.T) {
+	got := dedupModes(" exit-ip, config,,exit-ip ")
+	want := map[string]bool{"exit-ip": true, "config": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupModes() = %v, want %v", got, want)
+	}
+}
+
+func TestDedupByExitIP(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     []Result
+		wantKept    []string
+		wantDropped []string
+	}{
+		{
+			name: "keep fastest",
+			results: []Result{
+				{Name: "a", ExitIP: "1.1.1.1", Bandwidth: 10},
+				{Name: "b", ExitIP: "1.1.1.1", Bandwidth: 30},
+				{Name: "c", ExitIP: "2.2.2.2", Bandwidth: 20},
+				{Name: "d", ExitIP: "1.1.1.1", Bandwidth: 20},
+			},
+			wantKept:    []string{"b", "c"},
+			wantDropped: []string{"a", "d"},
+		},
+		{
+			name: "keep unknown exit ip",
+			results: []Result{
+				{Name: "a", Bandwidth: 10},
+				{Name: "b"},
+				{Name: "c", ExitIP: "1.1.1.1"},
+			},
+			wantKept: []string{"a", "b", "c"},
+		},
+		{
+			name: "first wins on tie",
+			results: []Result{
+				{Name: "a", ExitIP: "1.1.1.1", Bandwidth: 10},
+				{Name: "b", ExitIP: "1.1.1.1", Bandwidth: 10},
+			},
+			wantKept:    []string{"a"},
+			wantDropped: []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := dedupByExitIP(tt.results)
+			var keptNames []string
+			for _, result := range kept {
+				keptNames = append(keptNames, result.Name)
+			}
+			if !reflect.DeepEqual(keptNames, tt.wantKept) || !reflect.DeepEqual(dropped, tt.wantDropped) {
+				t.Errorf("dedupByExitIP() = %v, %v, want %v, %v", keptNames, dropped, tt.wantKept, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	a := map[string]any{"name": "a", "type": "ss", "server": "1.2.3.4", "port": 8388}
+	renamed := map[string]any{"name": "b", "type": "ss", "server": "1.2.3.4", "port": 8388}
+	changed := map[string]any{"name": "a", "type": "ss", "server": "1.2.3.4", "port": 8389}
+
+	if configFingerprint(a) != configFingerprint(renamed) {
+		t.Error("renamed proxy should have the same fingerprint")
+	}
+	if configFingerprint(a) == configFingerprint(changed) {
+		t.Error("changed proxy should have a different fingerprint")
+	}
+	if got := configFingerprint(nil); got != "" {
+		t.Errorf("configFingerprint(nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// diffThreshold 带宽变化超过该比例时认为节点变好或变差
+const diffThreshold = 0.2
+
+// runDiff 实现 diff 子命令，测试新旧两个配置并按节点名对比，列出新增、删除、变好和变差的节点，返回进程退出码
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(livenessObject, "l", *livenessObject, "liveness object, separated by comma to fallback to the next one when failed")
+	fs.IntVar(downloadSizeConfig, "size", *downloadSizeConfig, "download size for testing proxies(Mb)")
+	fs.IntVar(timeoutConfig, "timeout", *timeoutConfig, "timeout for testing proxies")
+	fs.IntVar(concurrent, "concurrent", *concurrent, "download concurrent size")
+	fs.StringVar(cacheTTLConfig, "cache", "", "reuse results of proxies tested within this duration, e.g. 6h, 1d")
+	fs.StringVar(cacheFile, "cache-file", *cacheFile, "file to store cached results for -cache")
+	fs.Var(&configHeaders, "config-header", "extra header for fetching remote configs, can be repeated")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: clash-speedtest diff [flags] old.yaml new.yaml")
+		return 2
+	}
+	if *cacheTTLConfig != "" {
+		ttl, err := parseDuration(*cacheTTLConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid cache ttl: %s\n", err)
+			return 2
+		}
+		if resultCache, err = loadResultCache(*cacheFile, ttl); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load result cache: %s\n", err)
+			return 1
+		}
+	} else {
+		// 两个配置中相同的节点只测试一次，失败的结果也复用，两边的结果才能比较
+		resultCache = &ResultCache{ttl: 24 * time.Hour, keepFailed: true, Entries: make(map[string]cacheEntry)}
+	}
+
+	var sides [2]map[string]*Result
+	for i, path := range fs.Args() {
+		fmt.Printf("\n===测试 %s===\n", path)
+		results, err := testConfigForDiff(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			return 1
+		}
+		sides[i] = results
+	}
+	if err := resultCache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save result cache: %s\n", err)
+	}
+	printDiff(sides[0], sides[1])
+	return 0
+}
+
+// testConfigForDiff 测试配置中的全部节点，配置相同的节点使用缓存的结果
+func testConfigForDiff(path string) (map[string]*Result, error) {
+	body, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := loadProxies(body)
+	if err != nil {
+		return nil, err
+	}
+	var livenessURLs []string
+	for _, livenessURL := range strings.Split(*livenessObject, ",") {
+		if livenessURL = strings.TrimSpace(livenessURL); livenessURL != "" {
+			livenessURLs = append(livenessURLs, livenessURL)
+		}
+	}
+	timeout := time.Duration(*timeoutConfig) * time.Second
+	names := testableProxies(filterProxies(".*", "", proxies), proxies)
+	sort.Strings(names)
+
+	columns := []Column{
+		{"节点", 42, func(r *Result) string { return formatName(r.Name) }},
+		{"带宽", 12, func(r *Result) string { return formatBandwidth(r.Bandwidth) }},
+		{"延迟", 12, func(r *Result) string { return formatMilliseconds(r.TTFB) }},
+	}
+	printHeader(columns)
+	results := make(map[string]*Result, len(names))
+	for _, name := range names {
+		key := resultCacheKey(proxies[name].SecretConfig)
+		result, ok := resultCache.Get(key, name)
+		if !ok {
+			for _, livenessURL := range livenessURLs {
+				result = TestProxyConcurrent(name, proxies[name], livenessURL, *downloadSizeConfig*1024*1024, timeout, *concurrent)
+				if result.Bandwidth > 0 {
+					break
+				}
+			}
+			resultCache.Put(key, *result)
+		}
+		result.Print(columns)
+		results[name] = result
+	}
+	return results, nil
+}
+
+type diffEntry struct {
+	Name     string
+	Old, New *Result
+}
+
+// classifyDiff 按节点名对比两次结果；可用性变化或带宽变化超过 diffThreshold 时认为节点变好或变差
+func classifyDiff(old, new map[string]*Result) (added, removed, improved, degraded []diffEntry) {
+	for name, result := range new {
+		previous, ok := old[name]
+		if !ok {
+			added = append(added, diffEntry{Name: name, New: result})
+			continue
+		}
+		entry := diffEntry{Name: name, Old: previous, New: result}
+		switch {
+		case previous.Bandwidth <= 0 && result.Bandwidth > 0:
+			improved = append(improved, entry)
+		case previous.Bandwidth > 0 && result.Bandwidth <= 0:
+			degraded = append(degraded, entry)
+		case previous.Bandwidth > 0 && result.Bandwidth > previous.Bandwidth*(1+diffThreshold):
+			improved = append(improved, entry)
+		case previous.Bandwidth > 0 && result.Bandwidth < previous.Bandwidth*(1-diffThreshold):
+			degraded = append(degraded, entry)
+		}
+	}
+	for name, result := range old {
+		if _, ok := new[name]; !ok {
+			removed = append(removed, diffEntry{Name: name, Old: result})
+		}
+	}
+	for _, entries := range [][]diffEntry{added, removed, improved, degraded} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return
+}
+
+func countAlive(results map[string]*Result) int {
+	alive := 0
+	for _, result := range results {
+		if result.Bandwidth > 0 {
+			alive++
+		}
+	}
+	return alive
+}
+
+func printDiff(old, new map[string]*Result) {
+	added, removed, improved, degraded := classifyDiff(old, new)
+	fmt.Println("\n\n===对比结果===")
+	fmt.Printf("可用节点：%d/%d -> %d/%d\n", countAlive(old), len(old), countAlive(new), len(new))
+
+	sections := []struct {
+		title   string
+		entries []diffEntry
+		color   string
+	}{
+		{"新增", added, green},
+		{"删除", removed, red},
+		{"变好", improved, green},
+		{"变差", degraded, red},
+	}
+	for _, section := range sections {
+		fmt.Printf("\n%s %d 个节点\n", section.title, len(section.entries))
+		for _, entry := range section.entries {
+			before, after := "-", "-"
+			if entry.Old != nil {
+				before = formatBandwidth(entry.Old.Bandwidth)
+			}
+			if entry.New != nil {
+				after = formatBandwidth(entry.New.Bandwidth)
+			}
+			fmt.Printf("%s%-42s\t%-12s -> %-12s\033[0m\n", section.color, formatName(entry.Name), before, after)
+		}
+	}
+}
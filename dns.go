@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dnsProbeIP 为 DNS 测试的目标，<随机前缀>-1-1-1-1.nip.io 由泛解析指向该地址，
+// 每次使用新的域名避免命中节点的 DNS 缓存
+const (
+	dnsProbeIP     = "1.1.1.1"
+	dnsProbeDomain = "nip.io"
+)
+
+// TestDNS 测量节点远程解析新域名的耗时：分别请求 IP 和解析到同一 IP 的新域名，两者首字节时间之差即为解析耗时
+func TestDNS(proxy C.Proxy, timeout time.Duration) time.Duration {
+	ipTTFB, err := probeTTFB(proxy, "http://"+dnsProbeIP+"/", timeout)
+	if err != nil {
+		return -1
+	}
+	prefix := make([]byte, 6)
+	if _, err := rand.Read(prefix); err != nil {
+		return -1
+	}
+	host := fmt.Sprintf("%s-%s.%s", hex.EncodeToString(prefix), strings.ReplaceAll(dnsProbeIP, ".", "-"), dnsProbeDomain)
+	hostTTFB, err := probeTTFB(proxy, "http://"+host+"/", timeout)
+	if err != nil {
+		return -1
+	}
+	// 解析命中缓存时差值可能为负，记为不到 1ms 而不是 0，0 表示未测试
+	if hostTTFB <= ipTTFB {
+		return time.Microsecond
+	}
+	return hostTTFB - ipTTFB
+}
+
+// probeTTFB 使用新连接请求 url 并返回首字节时间，域名由节点远程解析
+func probeTTFB(proxy C.Proxy, url string, timeout time.Duration) (time.Duration, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				return proxy.DialContext(ctx, &C.Metadata{Host: host, DstPort: 80})
+			},
+		},
+		// 只需要首字节时间，不跟随跳转
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	ttfb := time.Since(start)
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return ttfb, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// headerFlags 支持重复指定的 -config-header 参数
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, ":"); !ok {
+		return fmt.Errorf("header should be in Key: Value format")
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+var configHeaders, testHeaders headerFlags
+
+// applyTestHeaders 为测速请求附加 -test-ua 和 -test-header 指定的请求头
+func applyTestHeaders(req *http.Request) {
+	if *testUA != "" {
+		req.Header.Set("User-Agent", *testUA)
+	}
+	for _, header := range testHeaders {
+		key, value, _ := strings.Cut(header, ":")
+		req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+}
+
+// fetchClient 返回下载远程配置使用的 client，指定 -fetch-proxy 时通过该代理下载
+func fetchClient() (*http.Client, error) {
+	if *fetchProxy == "" {
+		return http.DefaultClient, nil
+	}
+	proxyURL, err := url.Parse(*fetchProxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fetch proxy: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported fetch proxy scheme: %s", proxyURL.Scheme)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}, nil
+}
+
+// fetchConfig 下载远程配置，附带 -config-header 和 -config-ua 指定的请求头；
+// 开启 -config-cache 时发送条件请求，下载失败时退回使用缓存
+func fetchConfig(configURL string) ([]byte, error) {
+	cached, err := loadConfigCache(configURL)
+	if err != nil {
+		log.Warnln("failed to load cached config of %s: %s", configURL, err)
+	}
+	if *offline {
+		if cached == nil {
+			return nil, fmt.Errorf("no cached config for %s", configURL)
+		}
+		recordSubscriptionInfo(configURL, cached.Userinfo)
+		return cached.Body, nil
+	}
+
+	entry, err := fetchConfigEntry(configURL, cached)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		log.Warnln("%s, use config cached at %s", err, cached.FetchedAt.Format("2006-01-02 15:04:05"))
+		entry = cached
+	}
+	recordSubscriptionInfo(configURL, entry.Userinfo)
+	return entry.Body, nil
+}
+
+func fetchConfigEntry(configURL string, cached *configCacheEntry) (*configCacheEntry, error) {
+	req, err := http.NewRequest(http.MethodGet, configURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch config: %w", err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	if *configUA != "" {
+		req.Header.Set("User-Agent", *configUA)
+	}
+	for _, header := range configHeaders {
+		key, value, _ := strings.Cut(header, ":")
+		req.Header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	client, err := fetchClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		if userinfo := resp.Header.Get("subscription-userinfo"); userinfo != "" {
+			cached.Userinfo = userinfo
+		}
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch config: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetch config: %w", err)
+	}
+
+	entry := &configCacheEntry{
+		URL:          configURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Userinfo:     resp.Header.Get("subscription-userinfo"),
+		FetchedAt:    time.Now(),
+		Body:         body,
+	}
+	if err := saveConfigCache(entry); err != nil {
+		log.Warnln("failed to cache config of %s: %s", configURL, err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type configCacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Userinfo     string    `json:"userinfo,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         []byte    `json:"body"`
+}
+
+func configCachePath(configURL string) string {
+	sum := sha256.Sum256([]byte(configURL))
+	return filepath.Join(*configCacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadConfigCache 读取订阅的缓存，未开启缓存或没有缓存时返回 nil
+func loadConfigCache(configURL string) (*configCacheEntry, error) {
+	if *configCacheDir == "" {
+		return nil, nil
+	}
+	buf, err := os.ReadFile(configCachePath(configURL))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry configCacheEntry
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func saveConfigCache(entry *configCacheEntry) error {
+	if *configCacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(*configCacheDir, 0o700); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// 订阅内容包含节点凭据，只允许当前用户读取
+	return os.WriteFile(configCachePath(entry.URL), buf, 0o600)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	C "github.com/Dreamacro/clash/constant"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置文件中的 type 写法与 AdapterType 名称不一致的部分
+var proxyTypeAliases = map[string]C.AdapterType{
+	"ss":     C.Shadowsocks,
+	"ssr":    C.ShadowsocksR,
+	"socks":  C.Socks5,
+	"socks5": C.Socks5,
+	"hy":     C.Hysteria,
+	"hy2":    C.Hysteria2,
+	"wg":     C.WireGuard,
+}
+
+func matchProxyType(t C.AdapterType, name string) bool {
+	if alias, ok := proxyTypeAliases[name]; ok {
+		return alias == t
+	}
+	return strings.EqualFold(t.String(), name)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
+
+// filterProxyTypes 按协议类型过滤节点，include 为空时不限制
+func filterProxyTypes(names []string, proxies map[string]CProxy, include string, exclude string) []string {
+	includeTypes, excludeTypes := splitList(include), splitList(exclude)
+	if len(includeTypes) == 0 && len(excludeTypes) == 0 {
+		return names
+	}
+
+	matchAny := func(t C.AdapterType, types []string) bool {
+		for _, name := range types {
+			if matchProxyType(t, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		t := proxies[name].Type()
+		if len(includeTypes) > 0 && !matchAny(t, includeTypes) {
+			continue
+		}
+		if matchAny(t, excludeTypes) {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered
+}
+
+// filterProxyCountries 解析节点服务器地址，只保留位于指定国家的节点
+func filterProxyCountries(names []string, proxies map[string]CProxy, geoip *GeoIP, countries string, timeout time.Duration) []string {
+	wanted := make(map[string]bool)
+	for _, country := range splitList(countries) {
+		wanted[strings.ToUpper(country)] = true
+	}
+	if len(wanted) == 0 {
+		return names
+	}
+
+	keep := make([]bool, len(names))
+	sem := make(chan struct{}, 16)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, proxy CProxy) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			country, _ := geoip.Lookup(resolveServerIP(proxy, timeout))
+			keep[i] = wanted[country]
+		}(i, proxies[name])
+	}
+	wg.Wait()
+
+	filtered := make([]string, 0, len(names))
+	for i, name := range names {
+		if keep[i] {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// filterProxyPorts 按节点服务器端口过滤，include 为空时不限制
+func filterProxyPorts(names []string, proxies map[string]CProxy, include string, exclude string) []string {
+	includePorts, excludePorts := make(map[string]bool), make(map[string]bool)
+	for _, port := range splitList(include) {
+		includePorts[port] = true
+	}
+	for _, port := range splitList(exclude) {
+		excludePorts[port] = true
+	}
+	if len(includePorts) == 0 && len(excludePorts) == 0 {
+		return names
+	}
+
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		_, port, err := net.SplitHostPort(proxies[name].Addr())
+		if err != nil {
+			continue
+		}
+		if len(includePorts) > 0 && !includePorts[port] {
+			continue
+		}
+		if excludePorts[port] {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered
+}
+
+// sampleProxies 随机抽取 n 个节点，保持原有顺序
+func sampleProxies(names []string, n int, rng *rand.Rand) []string {
+	if n <= 0 || n >= len(names) {
+		return names
+	}
+	picked := rng.Perm(len(names))[:n]
+	sort.Ints(picked)
+	sampled := make([]string, 0, n)
+	for _, i := range picked {
+		sampled = append(sampled, names[i])
+	}
+	return sampled
+}
+
+func limitProxies(names []string, n int) []string {
+	if n <= 0 || n >= len(names) {
+		return names
+	}
+	return names[:n]
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/Dreamacro/clash/component/resolver"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	geoipDownloadURL     = "https://github.com/P3TERX/GeoLite.mmdb/raw/download/GeoLite2-Country.mmdb"
+	geoipCityDownloadURL = "https://github.com/P3TERX/GeoLite.mmdb/raw/download/GeoLite2-City.mmdb"
+	exitIPURL            = "https://speed.cloudflare.com/cdn-cgi/trace"
+)
+
+// geoipCity 为 true 时数据库包含城市信息，才输出城市列
+var geoipCity bool
+
+// geoipURL 根据文件名选择下载的数据库，文件名包含 city 时下载城市数据库，否则下载国家数据库
+func geoipURL(path string) string {
+	if strings.Contains(strings.ToLower(filepath.Base(path)), "city") {
+		return geoipCityDownloadURL
+	}
+	return geoipDownloadURL
+}
+
+type GeoIP struct {
+	reader *maxminddb.Reader
+}
+
+type geoipRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// builtinGeoIP 为 -geoip 的特殊值，表示使用内置的数据库
+const builtinGeoIP = "builtin"
+
+// geoipCachePath 返回内置数据库更新后保存的位置
+func geoipCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "clash-speedtest", "GeoLite2-Country.mmdb"), nil
+}
+
+// loadBuiltinGeoIP 优先使用 -update-geoip 下载的数据库，其次是编译时内置的数据库，都没有时下载到缓存目录
+func loadBuiltinGeoIP() (*GeoIP, error) {
+	path, err := geoipCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && len(embeddedGeoIP) > 0 {
+		reader, err := maxminddb.FromBytes(embeddedGeoIP)
+		if err != nil {
+			return nil, err
+		}
+		return &GeoIP{reader: reader}, nil
+	}
+	return loadGeoIP(path)
+}
+
+// updateGeoIP 下载最新的数据库，path 为空或 builtin 时更新内置数据库
+func updateGeoIP(path string) (string, error) {
+	if path == "" || path == builtinGeoIP {
+		var err error
+		if path, err = geoipCachePath(); err != nil {
+			return "", err
+		}
+	}
+	return path, downloadMMDB(geoipURL(path), path)
+}
+
+// loadGeoIP 加载 mmdb 数据库，文件不存在时从 GeoLite 镜像下载
+func loadGeoIP(path string) (*GeoIP, error) {
+	if path == builtinGeoIP {
+		return loadBuiltinGeoIP()
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := downloadMMDB(geoipURL(path), path); err != nil {
+			return nil, fmt.Errorf("download geoip database: %w", err)
+		}
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// downloadMMDB 下载 mmdb 数据库并保存到 path
+func downloadMMDB(url string, path string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if _, err := maxminddb.FromBytes(buf); err != nil {
+		return fmt.Errorf("invalid database: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0o644)
+}
+
+// HasCity 判断数据库是否包含城市信息，如 GeoLite2-City
+func (g *GeoIP) HasCity() bool {
+	return strings.Contains(g.reader.Metadata.DatabaseType, "City")
+}
+
+// Lookup 返回 IP 所属的国家代码和城市，sing-geoip 格式的数据库只有国家代码
+func (g *GeoIP) Lookup(ip net.IP) (country string, city string) {
+	if ip == nil {
+		return "", ""
+	}
+	if g.reader.Metadata.DatabaseType == "sing-geoip" {
+		var code string
+		_ = g.reader.Lookup(ip, &code)
+		return strings.ToUpper(code), ""
+	}
+	var record geoipRecord
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return "", ""
+	}
+	return record.Country.IsoCode, record.City.Names["en"]
+}
+
+// resolveServerIP 解析节点服务器地址
+func resolveServerIP(proxy C.Proxy, timeout time.Duration) net.IP {
+	host, _, err := net.SplitHostPort(proxy.Addr())
+	if err != nil {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// 与节点连接时一样，启用配置中的 dns 时使用其解析
+	ip, err := resolver.ResolveProxyServerHost(ctx, host)
+	if err != nil {
+		return nil
+	}
+	return ip.AsSlice()
+}
+
+// detectExitIP 通过节点访问 trace 接口获取出口 IP
+func detectExitIP(proxy C.Proxy, timeout time.Duration) net.IP {
+	client := newProxyClient(proxy, timeout)
+	resp, err := client.Get(exitIPURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if ip, ok := strings.CutPrefix(scanner.Text(), "ip="); ok {
+			return net.ParseIP(ip)
+		}
+	}
+	return nil
+}
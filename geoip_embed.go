@@ -0,0 +1,12 @@
+//go:build embedgeoip
+
+package main
+
+import (
+	_ "embed"
+)
+
+// 发布时先下载 GeoLite2-Country.mmdb 到源码目录，再使用 -tags embedgeoip 编译
+//
+//go:embed GeoLite2-Country.mmdb
+var embeddedGeoIP []byte
@@ -0,0 +1,6 @@
+//go:build !embedgeoip
+
+package main
+
+// 未使用 -tags embedgeoip 编译时没有内置数据库，-geoip builtin 会在首次使用时下载
+var embeddedGeoIP []byte
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// grafanaMetrics 为 Grafana JSON 数据源支持的指标，target 为 指标 时返回每个节点一条曲线，
+// 为 指标:节点名 时只返回该节点
+var grafanaMetrics = map[string]func(r historyRecord) (float64, bool){
+	"bandwidth": func(r historyRecord) (float64, bool) { return r.Bandwidth, r.Bandwidth > 0 },
+	"ttfb":      func(r historyRecord) (float64, bool) { return float64(r.TTFB), r.Bandwidth > 0 },
+	"alive": func(r historyRecord) (float64, bool) {
+		if r.Bandwidth > 0 {
+			return 1, true
+		}
+		return 0, true
+	},
+}
+
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"` // [值, 毫秒时间戳]
+}
+
+// grafanaHandler 实现 Grafana JSON 数据源（SimpleJSON）的 /、/search 和 /query 接口，数据来自 -history
+func grafanaHandler(h *History) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grafana/", func(w http.ResponseWriter, r *http.Request) {
+		// 数据源的连通性测试
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/grafana/search", func(w http.ResponseWriter, r *http.Request) {
+		records, err := h.Load(time.Now().AddDate(0, 0, -30), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		names := make(map[string]bool)
+		for _, record := range records {
+			names[record.Name] = true
+		}
+		var targets []string
+		for metric := range grafanaMetrics {
+			targets = append(targets, metric)
+			for name := range names {
+				targets = append(targets, metric+":"+name)
+			}
+		}
+		sort.Strings(targets)
+		writeJSON(w, targets)
+	})
+	mux.HandleFunc("/grafana/query", func(w http.ResponseWriter, r *http.Request) {
+		var query grafanaQuery
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := h.Load(query.Range.From, query.Range.To)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		series := []*grafanaSeries{}
+		for _, target := range query.Targets {
+			metric, node, _ := strings.Cut(target.Target, ":")
+			value, ok := grafanaMetrics[metric]
+			if !ok {
+				continue
+			}
+			byNode := make(map[string]*grafanaSeries)
+			var names []string
+			for _, record := range records {
+				if node != "" && record.Name != node {
+					continue
+				}
+				v, ok := value(record)
+				if !ok {
+					continue
+				}
+				s, exists := byNode[record.Name]
+				if !exists {
+					s = &grafanaSeries{Target: record.Name + " " + metric, Datapoints: [][2]float64{}}
+					if node != "" {
+						s.Target = target.Target
+					}
+					byNode[record.Name] = s
+					names = append(names, record.Name)
+				}
+				s.Datapoints = append(s.Datapoints, [2]float64{v, float64(record.Time.UnixMilli())})
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				series = append(series, byNode[name])
+			}
+		}
+		writeJSON(w, series)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
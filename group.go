@@ -0,0 +1,84 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+	"regexp"
+)
+
+// groupProxyKeys 返回配置中名为 group 的分组递归引用的全部节点，found 表示配置中是否存在该分组；
+// proxies 为 loadProxies 解析出的同一份配置中的节点，use 引用的 provider 节点按分组的 filter 过滤
+func groupProxyKeys(buf []byte, group string, proxies map[string]CProxy) (keys []string, found bool) {
+	rawCfg := &RawConfig{}
+	if err := yaml.Unmarshal(buf, rawCfg); err != nil {
+		return nil, false
+	}
+	groups := make(map[string]map[string]any, len(rawCfg.ProxyGroups))
+	for _, config := range rawCfg.ProxyGroups {
+		if name, ok := config["name"].(string); ok {
+			groups[name] = config
+		}
+	}
+	if _, ok := groups[group]; !ok {
+		return nil, false
+	}
+
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	var walk func(name string)
+	walk = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		config := groups[name]
+		for _, member := range stringList(config["proxies"]) {
+			// -relay 时 relay 分组本身就是节点，不再展开
+			if proxy, ok := proxies[member]; ok && proxy.Provider == "" {
+				add(member)
+			} else if _, ok := groups[member]; ok {
+				walk(member)
+			}
+		}
+		var filter *regexp.Regexp
+		if pattern, ok := config["filter"].(string); ok && pattern != "" {
+			filter, _ = regexp.Compile(pattern)
+		}
+		for _, use := range stringList(config["use"]) {
+			for key, proxy := range proxies {
+				if proxy.Provider == use && (filter == nil || filter.MatchString(proxy.Name())) {
+					add(key)
+				}
+			}
+		}
+	}
+	walk(group)
+	return keys, true
+}
+
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// filterProxyGroup 只保留分组引用的节点
+func filterProxyGroup(names []string, members map[string]bool) []string {
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		if members[name] {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
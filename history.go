@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// history 为 nil 时不记录历史结果
+var history *History
+
+// historyRecord 为历史数据库中的一条记录，每次实际测试一个节点记录一条，使用缓存的结果不记录
+type historyRecord struct {
+	Time      time.Time `json:"time"`
+	Name      string    `json:"name"`
+	Bandwidth float64   `json:"bandwidth"` // 字节/秒，失败时为 0
+	TTFB      int64     `json:"ttfb_ms"`
+}
+
+// History 以 JSON Lines 格式追加保存每次的测试结果，文件只追加不改写，便于长期运行
+type History struct {
+	path    string
+	pending []historyRecord
+}
+
+func newHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Record 记录一个节点本次的测试结果，调用 Save 后写入文件
+func (h *History) Record(result Result) {
+	record := historyRecord{Time: time.Now(), Name: result.Name}
+	if result.Bandwidth > 0 {
+		record.Bandwidth, record.TTFB = result.Bandwidth, result.TTFB.Milliseconds()
+	}
+	h.pending = append(h.pending, record)
+}
+
+func (h *History) Save() error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+	fp, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	writer := bufio.NewWriter(fp)
+	encoder := json.NewEncoder(writer)
+	for _, record := range h.pending {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	h.pending = nil
+	return nil
+}
+
+// Load 读取 [from, to] 时间范围内的记录，文件不存在时返回空
+func (h *History) Load(from, to time.Time) ([]historyRecord, error) {
+	fp, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	var records []historyRecord
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		var record historyRecord
+		// 跳过写入中断留下的残缺行
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Time.Before(from) || record.Time.After(to) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/metacubex/quic-go"
+	"github.com/metacubex/quic-go/http3"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dialQUIC 通过节点的 UDP 转发建立 QUIC 连接，返回的 PacketConn 需要在测试结束后关闭
+func dialQUIC(ctx context.Context, proxy C.Proxy, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, net.PacketConn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	u16Port, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	ip, err := resolveTargetIP(ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pc, err := proxy.ListenPacketContext(ctx, &C.Metadata{
+		NetWork: C.UDP,
+		Host:    host,
+		DstIP:   ip,
+		DstPort: uint16(u16Port),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := quic.DialEarly(ctx, pc, net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(u16Port))), tlsCfg, cfg)
+	if err != nil {
+		pc.Close()
+		return nil, nil, err
+	}
+	return conn, pc, nil
+}
+
+// TestHTTP3 通过节点的 UDP 转发以 HTTP/3 下载测试对象，返回带宽，h3 不可用时返回 -1
+func TestHTTP3(proxy C.Proxy, livenessURL string, downloadSize int, timeout time.Duration) float64 {
+	var mu sync.Mutex
+	var packetConns []net.PacketConn
+	transport := &http3.RoundTripper{
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			conn, pc, err := dialQUIC(ctx, proxy, addr, tlsCfg, cfg)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			packetConns = append(packetConns, pc)
+			mu.Unlock()
+			return conn, nil
+		},
+	}
+	defer func() {
+		transport.Close()
+		mu.Lock()
+		for _, pc := range packetConns {
+			pc.Close()
+		}
+		mu.Unlock()
+	}()
+	client := &http.Client{Timeout: timeout, Transport: transport}
+
+	req, err := http.NewRequest(*testMethod, formatLivenessURL(livenessURL, downloadSize), nil)
+	if err != nil {
+		return -1
+	}
+	applyTestHeaders(req)
+	applySizeRange(req, livenessURL, downloadSize)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+	if !acceptStatus(resp.StatusCode) {
+		return -1
+	}
+	ttfb := time.Since(start)
+
+	// 与 TCP 下载一样按 -limit 限速
+	written, _ := io.Copy(&chunkWriter{ctx: req.Context()}, limitSizeBody(resp.Body, livenessURL, downloadSize))
+	if written == 0 {
+		return -1
+	}
+	return float64(written) / (time.Since(start) - ttfb).Seconds()
+}
+
+// formatHTTP3 显示 HTTP/3 带宽，节点不支持 UDP 时为 N/A
+func formatHTTP3(v float64) string {
+	if v < 0 {
+		return "failed"
+	}
+	return formatBandwidth(v)
+}
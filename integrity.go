@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// server 子命令的下载数据由 payloadSeedHeader 中的种子生成，-verify-payload 时客户端按同样的方式生成并逐字节比较
+const (
+	payloadSeedHeader = "X-Payload-Seed"
+	payloadBlockSize  = 1024 * 1024
+)
+
+// payloadBlocks 缓存按种子生成的数据块，同一个服务端的种子不变
+var payloadBlocks sync.Map
+
+// integrityError 表示下载的数据不完整或被修改，这部分数据不计入带宽
+type integrityError struct {
+	reason string
+}
+
+func (e *integrityError) Error() string {
+	return "payload " + e.reason
+}
+
+// payloadBlock 由种子生成 payloadBlockSize 字节无法压缩的数据，下载数据为该数据块的重复
+func payloadBlock(seed []byte) []byte {
+	block := make([]byte, 0, payloadBlockSize)
+	input := make([]byte, len(seed)+4)
+	copy(input, seed)
+	for counter := uint32(0); len(block) < payloadBlockSize; counter++ {
+		binary.BigEndian.PutUint32(input[len(seed):], counter)
+		sum := sha256.Sum256(input)
+		block = append(block, sum[:]...)
+	}
+	return block[:payloadBlockSize]
+}
+
+// expectedPayload 返回响应头中种子对应的数据块，响应不是由 server 子命令返回时报错
+func expectedPayload(header http.Header) ([]byte, error) {
+	seedHex := header.Get(payloadSeedHeader)
+	if block, ok := payloadBlocks.Load(seedHex); ok {
+		return block.([]byte), nil
+	}
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil || len(seed) == 0 {
+		return nil, &integrityError{reason: "unverifiable"}
+	}
+	block := payloadBlock(seed)
+	payloadBlocks.Store(seedHex, block)
+	return block, nil
+}
+
+// integrityWriter 将收到的数据与期望的数据块逐字节比较后交给下一个 Writer
+type integrityWriter struct {
+	next     *chunkWriter
+	expected []byte
+	offset   int
+}
+
+func (w *integrityWriter) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); {
+		n := len(w.expected) - w.offset
+		if n > len(p)-i {
+			n = len(p) - i
+		}
+		if !bytes.Equal(p[i:i+n], w.expected[w.offset:w.offset+n]) {
+			return 0, &integrityError{reason: "tampered"}
+		}
+		i += n
+		w.offset = (w.offset + n) % len(w.expected)
+	}
+	return w.next.Write(p)
+}
+
+// checkResponseIntegrity 检查响应是否被中间设备压缩
+func checkResponseIntegrity(resp *http.Response) error {
+	if resp.Uncompressed || resp.Header.Get("Content-Encoding") != "" {
+		return &integrityError{reason: "compressed"}
+	}
+	return nil
+}
+
+// integrityReason 返回错误中数据校验失败的原因，不是校验错误时返回空字符串
+func integrityReason(err error) string {
+	var integrityErr *integrityError
+	if errors.As(err, &integrityErr) {
+		return integrityErr.reason
+	}
+	return ""
+}
+
+func formatIntegrity(integrity string) string {
+	if integrity == "" {
+		return "N/A"
+	}
+	return integrity
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IPRisk struct {
+	Type  string // datacenter, residential, mobile
+	Score int    // 0-100，-1 表示服务不提供评分
+}
+
+// lookupIPRisk 查询出口 IP 的类型和风险评分，provider 支持 ip-api / ipinfo / scamalytics
+func lookupIPRisk(provider string, token string, ip string, timeout time.Duration) (*IPRisk, error) {
+	client := &http.Client{Timeout: timeout}
+	switch provider {
+	case "ip-api":
+		var body struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Mobile  bool   `json:"mobile"`
+			Proxy   bool   `json:"proxy"`
+			Hosting bool   `json:"hosting"`
+		}
+		if err := getJSON(client, fmt.Sprintf("http://ip-api.com/json/%s?fields=status,message,mobile,proxy,hosting", ip), &body); err != nil {
+			return nil, err
+		}
+		if body.Status != "success" {
+			return nil, fmt.Errorf("ip-api: %s", body.Message)
+		}
+		risk := &IPRisk{Type: "residential", Score: -1}
+		switch {
+		case body.Mobile:
+			risk.Type = "mobile"
+		case body.Hosting || body.Proxy:
+			risk.Type = "datacenter"
+		}
+		return risk, nil
+	case "ipinfo":
+		var body struct {
+			Company struct {
+				Type string `json:"type"`
+			} `json:"company"`
+			Privacy struct {
+				VPN     bool `json:"vpn"`
+				Proxy   bool `json:"proxy"`
+				Tor     bool `json:"tor"`
+				Hosting bool `json:"hosting"`
+			} `json:"privacy"`
+		}
+		endpoint := fmt.Sprintf("https://ipinfo.io/%s/json", ip)
+		if token != "" {
+			endpoint += "?token=" + url.QueryEscape(token)
+		}
+		if err := getJSON(client, endpoint, &body); err != nil {
+			return nil, err
+		}
+		risk := &IPRisk{Type: "residential", Score: -1}
+		if body.Company.Type == "hosting" || body.Privacy.Hosting {
+			risk.Type = "datacenter"
+		}
+		if body.Privacy.VPN || body.Privacy.Proxy || body.Privacy.Tor {
+			risk.Score = 100
+		}
+		return risk, nil
+	case "scamalytics":
+		user, key, ok := strings.Cut(token, ":")
+		if !ok {
+			return nil, fmt.Errorf("scamalytics token should be user:key")
+		}
+		var body struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+			Score  string `json:"score"`
+			ISP    struct {
+				Type string `json:"ip_type"`
+			} `json:"scamalytics_isp"`
+		}
+		endpoint := fmt.Sprintf("https://api11.scamalytics.com/%s/?ip=%s&key=%s", url.PathEscape(user), ip, url.QueryEscape(key))
+		if err := getJSON(client, endpoint, &body); err != nil {
+			return nil, err
+		}
+		if body.Status != "ok" {
+			return nil, fmt.Errorf("scamalytics: %s", body.Error)
+		}
+		score, err := strconv.Atoi(body.Score)
+		if err != nil {
+			return nil, fmt.Errorf("scamalytics: invalid score %q", body.Score)
+		}
+		risk := &IPRisk{Type: "residential", Score: score}
+		if strings.EqualFold(body.ISP.Type, "datacenter") || strings.EqualFold(body.ISP.Type, "hosting") {
+			risk.Type = "datacenter"
+		}
+		return risk, nil
+	default:
+		return nil, fmt.Errorf("unsupported ip risk provider: %s", provider)
+	}
+}
+
+func getJSON(client *http.Client, endpoint string, v any) error {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func formatIPRisk(risk *IPRisk) string {
+	if risk == nil {
+		return "N/A"
+	}
+	if risk.Score < 0 {
+		return risk.Type
+	}
+	return fmt.Sprintf("%s/%d", risk.Type, risk.Score)
+}
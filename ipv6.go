@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Dreamacro/clash/component/resolver"
+	"net"
+	"net/netip"
+)
+
+// resolveTargetIP 在本地解析测试地址，-ipv6 时只使用 IPv6 地址，否则优先使用 IPv4 地址
+func resolveTargetIP(ctx context.Context, host string) (netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if *forceIPv6 && !ip.Is6() {
+			return netip.Addr{}, fmt.Errorf("%s is not an ipv6 address", host)
+		}
+		return ip, nil
+	}
+	var ips []netip.Addr
+	var err error
+	if r := resolver.DefaultResolver; r != nil {
+		// 使用配置中的 dns 解析
+		if *forceIPv6 {
+			ips, err = r.LookupIPv6(ctx, host)
+		} else {
+			ips, err = r.LookupIP(ctx, host)
+		}
+	} else {
+		network := "ip"
+		if *forceIPv6 {
+			network = "ip6"
+		}
+		ips, err = net.DefaultResolver.LookupNetIP(ctx, network, host)
+	}
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	if len(ips) == 0 {
+		return netip.Addr{}, fmt.Errorf("resolve %s: no address", host)
+	}
+	for _, ip := range ips {
+		if ip.Is4() || ip.Is4In6() {
+			return ip.Unmap(), nil
+		}
+	}
+	return ips[0], nil
+}
+
+// formatIPv6 显示节点能否通过 IPv6 访问测试地址
+func formatIPv6(r *Result) string {
+	if r.Skipped {
+		return "N/A"
+	}
+	if r.Bandwidth > 0 {
+		return "yes"
+	}
+	return "no"
+}
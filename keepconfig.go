@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"math"
+	"os"
+)
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// writeKeepConfig 保留原配置中的 rules、proxy-groups、dns 等内容，只替换 proxies，并同步更新分组中的节点名
+func writeKeepConfig(filePath string, base []byte, results []Result, proxies map[string]CProxy) error {
+	// 原配置中保留了 proxy-providers，provider 中的节点不再写入 proxies
+	inline := make(map[string]CProxy, len(proxies))
+	for name, proxy := range proxies {
+		if proxy.Provider == "" {
+			inline[name] = proxy
+		}
+	}
+	proxies = inline
+
+	var nodes []any
+	var renamed map[string]string
+	if *isFilterUsed {
+		nodes, renamed = filteredProxyConfigs(results, proxies, *minBandwidth, *maxLatency)
+	} else if *inPlace {
+		// 原地更新时只去掉不可用的节点
+		nodes, renamed = filteredProxyConfigs(results, proxies, 0, math.MaxFloat64)
+	} else {
+		nodes = sortedProxyConfigs(results, proxies)
+		for _, name := range configOrderNames(proxies) {
+			if !contains(results, name) {
+				nodes = append(nodes, proxies[name].SecretConfig)
+			}
+		}
+	}
+
+	kept := make(map[string]bool)
+	filtered := nodes[:0]
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if name, ok := node.(map[string]any)["name"].(string); ok {
+			kept[name] = true
+		}
+		filtered = append(filtered, node)
+	}
+	nodes = filtered
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(base, &root); err != nil {
+		return err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("the first config is not a clash yaml config")
+	}
+	doc := root.Content[0]
+
+	var proxiesNode yaml.Node
+	if err := proxiesNode.Encode(nodes); err != nil {
+		return err
+	}
+	if *annotate {
+		annotateProxies(&proxiesNode, results)
+	}
+	original := make(map[string]bool)
+	if value := mappingValue(doc, "proxies"); value != nil {
+		for _, item := range value.Content {
+			if name := mappingValue(item, "name"); name != nil {
+				if alias, ok := anonymizedNames[name.Value]; ok {
+					name.Value = alias
+				}
+				original[name.Value] = true
+			}
+		}
+		*value = proxiesNode
+	} else {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "proxies"}, &proxiesNode)
+	}
+
+	if providers := mappingValue(doc, "proxy-providers"); providers != nil && *redact {
+		// 订阅地址中通常带有 token
+		for i := 1; i < len(providers.Content); i += 2 {
+			if u := mappingValue(providers.Content[i], "url"); u != nil {
+				u.Value = redactedValue
+			}
+		}
+	}
+
+	groups := mappingValue(doc, "proxy-groups")
+	if groups != nil {
+		for _, group := range groups.Content {
+			members := mappingValue(group, "proxies")
+			if members == nil {
+				continue
+			}
+			content := members.Content[:0]
+			for _, member := range members.Content {
+				if alias, ok := anonymizedNames[member.Value]; ok {
+					member.Value = alias
+				}
+				if newName, ok := renamed[member.Value]; ok {
+					member.Value = newName
+				} else if original[member.Value] && !kept[member.Value] {
+					continue
+				}
+				content = append(content, member)
+			}
+			if len(content) == 0 && mappingValue(group, "use") == nil {
+				content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Value: "DIRECT"})
+			}
+			members.Content = content
+		}
+	}
+
+	if *regionGroups {
+		var regionNode yaml.Node
+		if err := regionNode.Encode(regionProxyGroups(regionMembers(results, renamed))); err != nil {
+			return err
+		}
+		if groups != nil {
+			groups.Content = append(groups.Content, regionNode.Content...)
+		} else {
+			doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "proxy-groups"}, &regionNode)
+		}
+	}
+
+	buf, err := yaml.Marshal(&root)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, buf, 0o644)
+}
+
+// writeInPlace 将结果写回原配置文件，写入前备份为 .bak
+func writeInPlace(filePath string, base []byte, results []Result, proxies map[string]CProxy) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath+".bak", base, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("backup config: %w", err)
+	}
+	return writeKeepConfig(filePath, base, results, proxies)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestLatency 使用新连接请求 -latency-url 并返回收到响应头的耗时，与 Clash 面板中的 url-test 延迟口径一致，
+// 不受下载测试对象大小的影响
+func TestLatency(proxy C.Proxy, latencyURL string, timeout time.Duration) time.Duration {
+	client := newProxyClient(proxy, timeout)
+	req, err := http.NewRequest(http.MethodGet, latencyURL, nil)
+	if err != nil {
+		return -1
+	}
+	applyTestHeaders(req)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1
+	}
+	latency := time.Since(start)
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return -1
+	}
+	return latency
+}
+
+// latencyColumns 为只测试延迟（-controller、-mode urltest）时输出的列
+func latencyColumns() []Column {
+	return []Column{
+		{"节点", 42, func(r *Result) string { return formatName(r.Name) }},
+		{"延迟", 12, func(r *Result) string { return formatMilliseconds(r.TTFB) }},
+	}
+}
+
+// outputLatencyResults 按延迟排序并输出只测试了延迟的结果，没有带宽数据，只支持输出 csv
+func outputLatencyResults(results []Result, mode string) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].TTFB > 0) != (results[j].TTFB > 0) {
+			return results[i].TTFB > 0
+		}
+		return results[i].TTFB < results[j].TTFB
+	})
+	columns := latencyColumns()
+	fmt.Println("\n\n===结果按照延迟排序===")
+	printHeader(columns)
+	for _, result := range results {
+		result.Print(columns)
+	}
+
+	switch strings.ToLower(*output) {
+	case "":
+	case "csv":
+		path, err := outputPath()
+		if err != nil {
+			log.Fatalln("Failed to create output file: %s", err)
+		}
+		if err := writeToCSV(path, results, []Column{
+			{Header: "节点", Value: func(r *Result) string { return r.Name }},
+			{Header: "延迟 (ms)", Value: func(r *Result) string { return strconv.FormatInt(r.TTFB.Milliseconds(), 10) }},
+		}); err != nil {
+			log.Fatalln("Failed to write csv: %s", err)
+		}
+		if err := emitOutput(path); err != nil {
+			log.Fatalln("Failed to write output: %s", err)
+		}
+	default:
+		log.Fatalln("Output format %s is not supported with %s, only csv is supported", *output, mode)
+	}
+}
+
+// runURLTest 与 Clash 的 url-test 使用同样的方式测试延迟：每次新建连接请求 -latency-url（默认 generate_204），
+// 不下载测试对象，结果与 Clash 面板中显示的延迟一致
+func runURLTest(names []string, proxies map[string]CProxy, timeout time.Duration) {
+	testURL := controllerDelayURL
+	if *latencyURL != "" {
+		testURL = *latencyURL
+	}
+	columns := latencyColumns()
+	printHeader(columns)
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		result := Result{Name: name, TTFB: -1}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		delay, err := proxies[name].URLTest(ctx, testURL, nil, C.DropHistory)
+		cancel()
+		if err == nil {
+			result.TTFB = time.Duration(delay) * time.Millisecond
+		}
+		result.Print(columns)
+		results = append(results, result)
+	}
+	outputLatencyResults(results, "-mode urltest")
+}
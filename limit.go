@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Dreamacro/clash/component/dialer"
+	C "github.com/Dreamacro/clash/constant"
+	"golang.org/x/time/rate"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// testLimiter 为 -limit 对应的令牌桶，所有节点的测试流量共用，为 nil 时不限速
+var testLimiter *rate.Limiter
+
+// limitedRatio 带宽达到 -limit 的该比例时认为结果受限于限速，节点的实际带宽可能更高
+const limitedRatio = 0.9
+
+var rateUnits = []struct {
+	suffix string
+	bits   float64
+}{
+	{"gbps", 1e9},
+	{"mbps", 1e6},
+	{"kbps", 1e3},
+	{"bps", 1},
+}
+
+// parseRate 解析 50mbps、500kbps 格式的速率，返回字节/秒
+func parseRate(s string) (float64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	for _, unit := range rateUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || n <= 0 {
+			break
+		}
+		return n * unit.bits / 8, nil
+	}
+	return 0, fmt.Errorf("invalid rate: %s", s)
+}
+
+// newTestLimiter 按字节/秒创建令牌桶，桶容量为 100ms 的流量，不小于一次读取的大小
+func newTestLimiter(bytesPerSecond float64) *rate.Limiter {
+	burst := int(bytesPerSecond / 10)
+	if burst < 32*1024 {
+		burst = 32 * 1024
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// waitLimit 在 -limit 的令牌桶中取得 n 字节的额度，超过桶容量时分多次等待
+func waitLimit(ctx context.Context, n int) error {
+	if testLimiter == nil {
+		return nil
+	}
+	for n > 0 {
+		size := n
+		if size > testLimiter.Burst() {
+			size = testLimiter.Burst()
+		}
+		if err := testLimiter.WaitN(ctx, size); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
+
+// limitCeiling 返回带宽是否接近 -limit，此时结果只说明节点至少有这么快
+func limitCeiling(bandwidth float64) bool {
+	return testLimiter != nil && bandwidth >= float64(testLimiter.Limit())*limitedRatio
+}
+
+// limitedReader 按 -limit 限速读取，用于上传测试的请求体
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if err := waitLimit(r.ctx, n); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// maxNodeData 为 -max-node-data 对应的字节数，单个节点在一轮测试的所有项目中最多下载这么多数据，为 0 时不限制
+var maxNodeData int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"gb", 1024 * 1024 * 1024},
+	{"mb", 1024 * 1024},
+	{"kb", 1024},
+	{"b", 1},
+}
+
+// parseSize 解析 30MB、512KB 格式的数据量，返回字节数
+func parseSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || n <= 0 {
+			break
+		}
+		return int64(n * float64(unit.bytes)), nil
+	}
+	return 0, fmt.Errorf("invalid size: %s", s)
+}
+
+// errDataCap 表示下载量达到 -max-node-data，已下载的部分仍计入带宽
+var errDataCap = errors.New("reached max node data")
+
+// cappedProxy 统计节点所有 TCP 连接和 UDP 转发收到的字节数，达到 -max-node-data 后读取返回 errDataCap
+type cappedProxy struct {
+	C.Proxy
+	used *int64
+}
+
+// capNodeData 为节点创建新的 -max-node-data 额度，下载测试、其他目标、出口 IP 等项目共用，未设置时原样返回
+func capNodeData(proxy C.Proxy) C.Proxy {
+	if maxNodeData <= 0 {
+		return proxy
+	}
+	return &cappedProxy{Proxy: proxy, used: new(int64)}
+}
+
+func (p *cappedProxy) DialContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.Conn, error) {
+	conn, err := p.Proxy.DialContext(ctx, metadata, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &cappedConn{Conn: conn, used: p.used}, nil
+}
+
+func (p *cappedProxy) ListenPacketContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.PacketConn, error) {
+	pc, err := p.Proxy.ListenPacketContext(ctx, metadata, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &cappedPacketConn{PacketConn: pc, used: p.used}, nil
+}
+
+// remainingData 返回节点还能下载的字节数
+func remainingData(used *int64) int64 {
+	return maxNodeData - atomic.LoadInt64(used)
+}
+
+type cappedConn struct {
+	C.Conn
+	used *int64
+}
+
+func (c *cappedConn) Read(p []byte) (int, error) {
+	remaining := remainingData(c.used)
+	if remaining <= 0 {
+		return 0, errDataCap
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(c.used, int64(n))
+	return n, err
+}
+
+type cappedPacketConn struct {
+	C.PacketConn
+	used *int64
+}
+
+func (c *cappedPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if remainingData(c.used) <= 0 {
+		return 0, nil, errDataCap
+	}
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	atomic.AddInt64(c.used, int64(n))
+	return n, addr, err
+}
+
+func (c *cappedPacketConn) WaitReadFrom() ([]byte, func(), net.Addr, error) {
+	if remainingData(c.used) <= 0 {
+		return nil, nil, nil, errDataCap
+	}
+	data, put, addr, err := c.PacketConn.WaitReadFrom()
+	atomic.AddInt64(c.used, int64(len(data)))
+	return data, put, addr, err
+}
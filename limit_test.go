@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "30MB", want: 30 * 1024 * 1024},
+		{in: "512kb", want: 512 * 1024},
+		{in: " 1.5 GB ", want: 1536 * 1024 * 1024},
+		{in: "100b", want: 100},
+		{in: "30", wantErr: true},
+		{in: "0MB", wantErr: true},
+		{in: "-1MB", wantErr: true},
+		{in: "MB", wantErr: true},
+		{in: "30TB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "50mbps", want: 50e6 / 8},
+		{in: "500Kbps", want: 500e3 / 8},
+		{in: "1gbps", want: 1e9 / 8},
+		{in: "800 bps", want: 100},
+		{in: "50MB/s", wantErr: true},
+		{in: "0mbps", wantErr: true},
+		{in: "fastmbps", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRate(%q) = %v, %v, want %v, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func configString(config map[string]any, key string) string {
+	if v, ok := config[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func configSubMap(config map[string]any, key string) map[string]any {
+	switch v := config[key].(type) {
+	case map[string]any:
+		return v
+	case map[string]string:
+		m := make(map[string]any, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+	return map[string]any{}
+}
+
+func configBool(config map[string]any, key string) bool {
+	v, _ := config[key].(bool)
+	return v
+}
+
+// transportQuery 将 ws/grpc/tls 等传输层参数写入 v2rayN 风格的 query
+func transportQuery(config map[string]any, query url.Values, sniKey string) {
+	if sni := configString(config, sniKey); sni != "" {
+		query.Set("sni", sni)
+	}
+	if configBool(config, "skip-cert-verify") {
+		query.Set("allowInsecure", "1")
+	}
+	if fp := configString(config, "client-fingerprint"); fp != "" {
+		query.Set("fp", fp)
+	}
+	switch network := configString(config, "network"); network {
+	case "ws":
+		query.Set("type", "ws")
+		wsOpts := configSubMap(config, "ws-opts")
+		query.Set("path", configString(wsOpts, "path"))
+		if host := configString(configSubMap(wsOpts, "headers"), "Host"); host != "" {
+			query.Set("host", host)
+		}
+	case "grpc":
+		query.Set("type", "grpc")
+		query.Set("serviceName", configString(configSubMap(config, "grpc-opts"), "grpc-service-name"))
+	case "", "tcp":
+		query.Set("type", "tcp")
+	default:
+		query.Set("type", network)
+	}
+}
+
+// proxyToLink 将 Clash 节点配置转换为分享链接，name 会替换原有节点名
+func proxyToLink(config map[string]any, name string) (string, error) {
+	server, port := configString(config, "server"), configString(config, "port")
+	u := &url.URL{
+		Host:     net.JoinHostPort(server, port),
+		Fragment: name,
+	}
+	query := url.Values{}
+
+	switch proxyType := configString(config, "type"); proxyType {
+	case "ss":
+		u.Scheme = "ss"
+		userInfo := configString(config, "cipher") + ":" + configString(config, "password")
+		u.User = url.User(base64.RawURLEncoding.EncodeToString([]byte(userInfo)))
+		if plugin := configString(config, "plugin"); plugin != "" {
+			opts := configSubMap(config, "plugin-opts")
+			switch plugin {
+			case "obfs":
+				query.Set("plugin", fmt.Sprintf("obfs-local;obfs=%s;obfs-host=%s", configString(opts, "mode"), configString(opts, "host")))
+			case "v2ray-plugin":
+				value := fmt.Sprintf("v2ray-plugin;mode=%s;host=%s;path=%s", configString(opts, "mode"), configString(opts, "host"), configString(opts, "path"))
+				if configBool(opts, "tls") {
+					value += ";tls"
+				}
+				query.Set("plugin", value)
+			default:
+				return "", fmt.Errorf("unsupported ss plugin %s", plugin)
+			}
+		}
+	case "vmess":
+		network := configString(config, "network")
+		if network == "" {
+			network = "tcp"
+		}
+		wsOpts := configSubMap(config, "ws-opts")
+		path := configString(wsOpts, "path")
+		if network == "grpc" {
+			path = configString(configSubMap(config, "grpc-opts"), "grpc-service-name")
+		}
+		tls := ""
+		if configBool(config, "tls") {
+			tls = "tls"
+		}
+		buf, err := json.Marshal(map[string]any{
+			"v":    "2",
+			"ps":   name,
+			"add":  server,
+			"port": port,
+			"id":   configString(config, "uuid"),
+			"aid":  configString(config, "alterId"),
+			"scy":  configString(config, "cipher"),
+			"net":  network,
+			"type": "none",
+			"host": configString(configSubMap(wsOpts, "headers"), "Host"),
+			"path": path,
+			"tls":  tls,
+			"sni":  configString(config, "servername"),
+		})
+		if err != nil {
+			return "", err
+		}
+		return "vmess://" + base64.StdEncoding.EncodeToString(buf), nil
+	case "vless":
+		u.Scheme = "vless"
+		u.User = url.User(configString(config, "uuid"))
+		query.Set("encryption", "none")
+		if flow := configString(config, "flow"); flow != "" {
+			query.Set("flow", flow)
+		}
+		security := "none"
+		if configBool(config, "tls") {
+			security = "tls"
+		}
+		if reality, ok := config["reality-opts"].(map[string]any); ok {
+			security = "reality"
+			query.Set("pbk", configString(reality, "public-key"))
+			query.Set("sid", configString(reality, "short-id"))
+		}
+		query.Set("security", security)
+		transportQuery(config, query, "servername")
+	case "trojan":
+		u.Scheme = "trojan"
+		u.User = url.User(configString(config, "password"))
+		transportQuery(config, query, "sni")
+	case "hysteria2":
+		u.Scheme = "hysteria2"
+		u.User = url.User(configString(config, "password"))
+		if sni := configString(config, "sni"); sni != "" {
+			query.Set("sni", sni)
+		}
+		if obfs := configString(config, "obfs"); obfs != "" {
+			query.Set("obfs", obfs)
+			query.Set("obfs-password", configString(config, "obfs-password"))
+		}
+		if configBool(config, "skip-cert-verify") {
+			query.Set("insecure", "1")
+		}
+	case "hysteria":
+		u.Scheme = "hysteria"
+		query.Set("protocol", configString(config, "protocol"))
+		query.Set("auth", configString(config, "auth-str"))
+		query.Set("peer", configString(config, "sni"))
+		query.Set("upmbps", configString(config, "up"))
+		query.Set("downmbps", configString(config, "down"))
+		if obfs := configString(config, "obfs"); obfs != "" {
+			query.Set("obfs", obfs)
+		}
+		if configBool(config, "skip-cert-verify") {
+			query.Set("insecure", "1")
+		}
+	case "tuic":
+		u.Scheme = "tuic"
+		u.User = url.UserPassword(configString(config, "uuid"), configString(config, "password"))
+		if sni := configString(config, "sni"); sni != "" {
+			query.Set("sni", sni)
+		}
+		if cc := configString(config, "congestion-controller"); cc != "" {
+			query.Set("congestion_control", cc)
+		}
+		if mode := configString(config, "udp-relay-mode"); mode != "" {
+			query.Set("udp_relay_mode", mode)
+		}
+		if alpn := configStrings(config, "alpn"); len(alpn) > 0 {
+			query.Set("alpn", strings.Join(alpn, ","))
+		}
+	case "socks5":
+		u.Scheme = "socks"
+		if username := configString(config, "username"); username != "" {
+			userInfo := username + ":" + configString(config, "password")
+			u.User = url.User(base64.RawURLEncoding.EncodeToString([]byte(userInfo)))
+		}
+	default:
+		return "", fmt.Errorf("unsupported proxy type %s", proxyType)
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// shareLinks 生成通过测试的节点的分享链接，节点名按 -rename 重命名
+func shareLinks(results []Result, proxies map[string]CProxy) []string {
+	links := make([]string, 0, len(results))
+	for _, result := range results {
+		proxy, ok := proxies[result.Name]
+		if !ok {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		link, err := proxyToLink(config, outputName(config, result, len(links)+1))
+		if err != nil {
+			log.Warnln("skip %s: %s", result.Name, err)
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func writeLinks(filePath string, results []Result, proxies map[string]CProxy) error {
+	links := shareLinks(results, proxies)
+	return os.WriteFile(filePath, []byte(strings.Join(links, "\n")+"\n"), 0o644)
+}
+
+// writeSubscription 输出 base64 编码的订阅文件，可直接导入 v2rayN / Shadowrocket
+func writeSubscription(filePath string, results []Result, proxies map[string]CProxy) error {
+	links := shareLinks(results, proxies)
+	content := base64.StdEncoding.EncodeToString([]byte(strings.Join(links, "\n")))
+	return os.WriteFile(filePath, []byte(content), 0o644)
+}
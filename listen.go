@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// listenAddr 补全 -serve-best、-grafana 等监听地址：未指定主机时只监听 127.0.0.1，
+// 监听其他地址时必须通过 authFlag 指定 user:pass，避免对外开放
+func listenAddr(addr string, auth string, authFlag string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if auth != "" && !strings.Contains(auth, ":") {
+		return "", fmt.Errorf("%s should be in user:pass format", authFlag)
+	}
+	if auth == "" && !isLoopbackHost(host) {
+		return "", fmt.Errorf("listening on %s requires %s", host, authFlag)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// isLoopbackHost 判断监听地址是否只能从本机访问
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkAuth 以固定时间比较客户端提供的用户名和密码与 user:pass 格式的 auth
+func checkAuth(auth, user, pass string) bool {
+	return subtle.ConstantTimeCompare([]byte(user+":"+pass), []byte(auth)) == 1
+}
+
+// basicAuthHandler 在 auth 不为空时要求请求带有匹配的 Basic 认证
+func basicAuthHandler(auth string, next http.Handler) http.Handler {
+	if auth == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !checkAuth(auth, user, pass) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="clash-speedtest"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		auth    string
+		want    string
+		wantErr bool
+	}{
+		{addr: ":3001", want: "127.0.0.1:3001"},
+		{addr: "localhost:3001", want: "localhost:3001"},
+		{addr: "[::1]:3001", want: "[::1]:3001"},
+		{addr: "0.0.0.0:3001", wantErr: true},
+		{addr: "0.0.0.0:3001", auth: "user:pass", want: "0.0.0.0:3001"},
+		{addr: "0.0.0.0:3001", auth: "user", wantErr: true},
+		{addr: "3001", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := listenAddr(tt.addr, tt.auth, "-grafana-auth")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("listenAddr(%q, %q) = %q, %v, want %q, wantErr %v", tt.addr, tt.auth, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBasicAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		auth string
+		user string
+		pass string
+		want int
+	}{
+		{auth: "", want: http.StatusOK},
+		{auth: "user:pass", want: http.StatusUnauthorized},
+		{auth: "user:pass", user: "user", pass: "wrong", want: http.StatusUnauthorized},
+		{auth: "user:pass", user: "user", pass: "pass", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/grafana/", nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		rec := httptest.NewRecorder()
+		basicAuthHandler(tt.auth, ok).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("auth %q, user %q, pass %q: status = %d, want %d", tt.auth, tt.user, tt.pass, rec.Code, tt.want)
+		}
+	}
+}
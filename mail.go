@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer 通过 SMTP 发送测试报告，正文为 html 表格，附件为 csv
+type Mailer struct {
+	addr     string
+	user     string
+	password string
+	from     string
+	to       []string
+}
+
+func newMailer(addr, user, password, from, to string) (*Mailer, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid smtp address: %w", err)
+	}
+	if from == "" {
+		from = user
+	}
+	if from == "" {
+		return nil, fmt.Errorf("-mail-from is required")
+	}
+	m := &Mailer{addr: addr, user: user, password: password, from: from}
+	for _, item := range strings.Split(to, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			m.to = append(m.to, item)
+		}
+	}
+	if len(m.to) == 0 {
+		return nil, fmt.Errorf("-mail-to is required")
+	}
+	return m, nil
+}
+
+// reportHTML 将结果渲染为 html 表格，列与 csv 输出相同
+func reportHTML(results []Result, columns []Column) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<p>%d/%d 个节点可用</p>\n", len(passedResults(results)), len(results))
+	sb.WriteString(`<table border="1" cellspacing="0" cellpadding="4">` + "\n<tr>")
+	for _, column := range columns {
+		fmt.Fprintf(&sb, "<th>%s</th>", html.EscapeString(column.Header))
+	}
+	sb.WriteString("</tr>\n")
+	for i := range results {
+		sb.WriteString("<tr>")
+		for _, column := range columns {
+			fmt.Fprintf(&sb, "<td>%s</td>", html.EscapeString(column.Value(&results[i])))
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>\n")
+	return sb.String()
+}
+
+// buildReport 生成 multipart/mixed 邮件，包含 html 正文和 csv 附件
+func (m *Mailer) buildReport(results []Result) ([]byte, error) {
+	columns := csvColumns()
+	var attachment bytes.Buffer
+	if err := encodeCSV(&attachment, results, columns); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	now := time.Now()
+	header := []string{
+		"From: " + m.from,
+		"To: " + strings.Join(m.to, ", "),
+		"Subject: " + mime.BEncoding.Encode("utf-8", "测速报告 "+now.Format("2006-01-02 15:04")),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + writer.Boundary(),
+	}
+	body.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(part, []byte(reportHTML(results, columns))); err != nil {
+		return nil, err
+	}
+	part, err = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/csv; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="speedtest.csv"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(part, attachment.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// writeBase64 按每行 76 个字符写入 base64 编码的内容
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// SendReport 发送测试报告，465 端口使用隐式 TLS，其他端口在服务器支持时使用 STARTTLS
+func (m *Mailer) SendReport(results []Result) error {
+	msg, err := m.buildReport(results)
+	if err != nil {
+		return err
+	}
+	host, port, _ := net.SplitHostPort(m.addr)
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, host)
+	}
+	if port != "465" {
+		return smtp.SendMail(m.addr, auth, m.from, m.to, msg)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", m.addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
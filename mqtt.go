@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// publishMQTT 连接 MQTT broker，以保留消息发布每个节点的结果和本次测试的汇总：
+// <topic>/node/<节点名> 和 <topic>/summary，只使用 MQTT 3.1.1 的 QoS 0 发布
+func publishMQTT(brokerURL string, topic string, results []Result, timeout time.Duration) error {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return err
+	}
+	conn, err := dialMQTT(u, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	password, _ := u.User.Password()
+	clientID := fmt.Sprintf("clash-speedtest-%d", time.Now().UnixNano()%1e6)
+	if err := mqttConnect(conn, clientID, u.User.Username(), password); err != nil {
+		return err
+	}
+
+	// 发布的主题中不能有通配符，speedtest/# 视为 speedtest
+	topic = strings.TrimSuffix(strings.TrimSuffix(topic, "#"), "/")
+	type nodeMessage struct {
+		Name      string  `json:"name"`
+		Alive     bool    `json:"alive"`
+		Bandwidth float64 `json:"bandwidth"` // 字节/秒
+		TTFB      int64   `json:"ttfb_ms"`
+		Country   string  `json:"country,omitempty"`
+	}
+	for _, result := range results {
+		msg := nodeMessage{Name: result.Name, Alive: result.Bandwidth > 0, Country: result.Country}
+		if msg.Alive {
+			msg.Bandwidth, msg.TTFB = result.Bandwidth, result.TTFB.Milliseconds()
+		}
+		if err := mqttPublishJSON(conn, topic+"/node/"+mqttTopicEscape(result.Name), msg); err != nil {
+			return err
+		}
+	}
+
+	summary := struct {
+		Total  int    `json:"total"`
+		Passed int    `json:"passed"`
+		Best   string `json:"best,omitempty"`
+		Time   string `json:"time"`
+	}{Total: len(results), Passed: len(passedResults(results)), Time: time.Now().Format(time.RFC3339)}
+	if top := topResults(results, 1); len(top) > 0 {
+		summary.Best = top[0].Name
+	}
+	if err := mqttPublishJSON(conn, topic+"/summary", summary); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte{0xe0, 0x00})
+	return err
+}
+
+// dialMQTT 支持 tcp://、mqtt:// 和使用 TLS 的 ssl://、mqtts://，未指定端口时使用默认端口
+func dialMQTT(u *url.URL, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	host := u.Host
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		return dialer.Dial("tcp", host)
+	case "ssl", "tls", "mqtts":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		return tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	}
+	return nil, fmt.Errorf("unsupported mqtt scheme: %s", u.Scheme)
+}
+
+var mqttTopicReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func mqttTopicEscape(name string) string {
+	return mqttTopicReplacer.Replace(formatName(name))
+}
+
+func mqttString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(byte(len(s) >> 8))
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+}
+
+// mqttPacket 返回带固定头的报文，剩余长度使用变长编码
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttConnectErrors 为 CONNACK 中非 0 返回码的含义
+var mqttConnectErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// mqttConnect 发送 CONNECT 并等待 CONNACK，使用 clean session，不保留会话状态
+func mqttConnect(conn io.ReadWriter, clientID, username, password string) error {
+	var body bytes.Buffer
+	mqttString(&body, "MQTT")
+	body.WriteByte(4) // 协议级别 3.1.1
+	flags := byte(0x02)
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	body.Write([]byte{0x00, 0x3c}) // keep alive 60 秒
+	mqttString(&body, clientID)
+	if username != "" {
+		mqttString(&body, username)
+		if password != "" {
+			mqttString(&body, password)
+		}
+	}
+	if _, err := conn.Write(mqttPacket(0x10, body.Bytes())); err != nil {
+		return err
+	}
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("read connack: %w", err)
+	}
+	if ack[0] != 0x20 || ack[1] != 0x02 {
+		return fmt.Errorf("unexpected mqtt packet: 0x%02x 0x%02x", ack[0], ack[1])
+	}
+	if code := ack[3]; code != 0 {
+		if reason, ok := mqttConnectErrors[code]; ok {
+			return fmt.Errorf("mqtt connection refused: %s", reason)
+		}
+		return fmt.Errorf("mqtt connection refused, return code %d", code)
+	}
+	return nil
+}
+
+// mqttPublishJSON 以 QoS 0 发布保留消息，订阅者连接后立即收到最新结果
+func mqttPublishJSON(conn io.Writer, topic string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	mqttString(&body, topic)
+	body.Write(payload)
+	_, err = conn.Write(mqttPacket(0x31, body.Bytes()))
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMQTTPacketRemainingLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{n: 0, want: []byte{0x00}},
+		{n: 127, want: []byte{0x7f}},
+		{n: 128, want: []byte{0x80, 0x01}},
+		{n: 16383, want: []byte{0xff, 0x7f}},
+		{n: 16384, want: []byte{0x80, 0x80, 0x01}},
+		{n: 2097151, want: []byte{0xff, 0xff, 0x7f}},
+		{n: 2097152, want: []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{0xaa}, tt.n)
+		packet := mqttPacket(0x30, body)
+		want := append(append([]byte{0x30}, tt.want...), body...)
+		if !bytes.Equal(packet, want) {
+			t.Errorf("mqttPacket with %d bytes body: header = % x, want % x", tt.n, packet[:len(packet)-tt.n], want[:len(want)-tt.n])
+		}
+	}
+}
+
+// mqttConn 从 in 读取 broker 的回复，写入的报文保存在 out 中
+type mqttConn struct {
+	io.Reader
+	out bytes.Buffer
+}
+
+func (c *mqttConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func TestMQTTConnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     []byte
+	}{
+		{
+			name: "anonymous",
+			want: []byte{0x10, 13, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x02, 0, 60, 0, 1, 'c'},
+		},
+		{
+			name:     "username",
+			username: "u",
+			want:     []byte{0x10, 16, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x82, 0, 60, 0, 1, 'c', 0, 1, 'u'},
+		},
+		{
+			name:     "password",
+			username: "u",
+			password: "p",
+			want:     []byte{0x10, 19, 0, 4, 'M', 'Q', 'T', 'T', 4, 0xc2, 0, 60, 0, 1, 'c', 0, 1, 'u', 0, 1, 'p'},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mqttConn{Reader: bytes.NewReader([]byte{0x20, 0x02, 0x00, 0x00})}
+			if err := mqttConnect(conn, "c", tt.username, tt.password); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.want) {
+				t.Errorf("connect packet = % x, want % x", conn.out.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMQTTConnack(t *testing.T) {
+	tests := []struct {
+		ack     []byte
+		wantErr string
+	}{
+		{ack: []byte{0x20, 0x02, 0x00, 0x00}},
+		{ack: []byte{0x20, 0x02, 0x01, 0x00}},
+		{ack: []byte{0x20, 0x02, 0x00, 0x01}, wantErr: "unacceptable protocol version"},
+		{ack: []byte{0x20, 0x02, 0x00, 0x02}, wantErr: "identifier rejected"},
+		{ack: []byte{0x20, 0x02, 0x00, 0x03}, wantErr: "server unavailable"},
+		{ack: []byte{0x20, 0x02, 0x00, 0x04}, wantErr: "bad user name or password"},
+		{ack: []byte{0x20, 0x02, 0x00, 0x05}, wantErr: "not authorized"},
+		{ack: []byte{0x20, 0x02, 0x00, 0x06}, wantErr: "return code 6"},
+		{ack: []byte{0x30, 0x02, 0x00, 0x00}, wantErr: "unexpected mqtt packet"},
+		{ack: []byte{0x20, 0x02}, wantErr: "read connack"},
+	}
+	for _, tt := range tests {
+		err := mqttConnect(&mqttConn{Reader: bytes.NewReader(tt.ack)}, "c", "", "")
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("connack % x: err = %v, want %q", tt.ack, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMQTTPublishJSON(t *testing.T) {
+	tests := []struct {
+		topic   string
+		payload string
+		want    []byte
+	}{
+		{
+			topic:   "a/b",
+			payload: "x",
+			want:    []byte{0x31, 8, 0, 3, 'a', '/', 'b', '"', 'x', '"'},
+		},
+		{
+			// 报文体为 2 + 3 + 123 = 128 字节，剩余长度占两个字节
+			topic:   "a/b",
+			payload: strings.Repeat("x", 121),
+			want:    append(append([]byte{0x31, 0x80, 0x01, 0, 3, 'a', '/', 'b', '"'}, strings.Repeat("x", 121)...), '"'),
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := mqttPublishJSON(&buf, tt.topic, tt.payload); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("publish packet = % x, want % x", buf.Bytes(), tt.want)
+		}
+	}
+}
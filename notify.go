@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// notifyTopN 为通知中列出的节点数量
+const notifyTopN = 10
+
+// topResults 返回可用节点中排名前 n 的结果，未指定 -sort 时按带宽排序
+func topResults(results []Result, n int) []Result {
+	top := passedResults(results)
+	if len(sortKeys) == 0 {
+		sort.SliceStable(top, func(i, j int) bool {
+			return top[i].Bandwidth > top[j].Bandwidth
+		})
+	}
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// notifyTarget 为 -notify 指定的 webhook，Kind 为 discord 或 slack
+type notifyTarget struct {
+	Kind string
+	URL  string
+}
+
+type notifyFlags []notifyTarget
+
+func (n *notifyFlags) String() string {
+	items := make([]string, 0, len(*n))
+	for _, target := range *n {
+		items = append(items, target.Kind)
+	}
+	return strings.Join(items, ", ")
+}
+
+func (n *notifyFlags) Set(value string) error {
+	kind, url, ok := strings.Cut(value, "=")
+	if !ok || url == "" {
+		return fmt.Errorf("notify should be in kind=URL format")
+	}
+	kind = strings.ToLower(kind)
+	switch kind {
+	case "discord", "slack":
+	default:
+		return fmt.Errorf("unsupported notify kind: %s", kind)
+	}
+	*n = append(*n, notifyTarget{Kind: kind, URL: url})
+	return nil
+}
+
+var notifyTargets notifyFlags
+
+// discordPayload 使用一个 embed，每个节点一个字段
+func discordPayload(results []Result) any {
+	type field struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	fields := []field{}
+	for i, result := range topResults(results, notifyTopN) {
+		fields = append(fields, field{
+			Name:  fmt.Sprintf("%d. %s", i+1, formatName(result.Name)),
+			Value: fmt.Sprintf("%s · %s", formatBandwidth(result.Bandwidth), formatMilliseconds(result.TTFB)),
+		})
+	}
+	return map[string]any{
+		"embeds": []any{map[string]any{
+			"title":       "测速完成",
+			"description": fmt.Sprintf("%d/%d 个节点可用", len(passedResults(results)), len(results)),
+			"color":       0x2ecc71,
+			"fields":      fields,
+			"timestamp":   time.Now().Format(time.RFC3339),
+		}},
+	}
+}
+
+// slackPayload 使用 header 和 section 两个 block，text 为通知中显示的摘要
+func slackPayload(results []Result) any {
+	summary := fmt.Sprintf("测速完成：%d/%d 个节点可用", len(passedResults(results)), len(results))
+	var lines []string
+	for i, result := range topResults(results, notifyTopN) {
+		lines = append(lines, fmt.Sprintf("%d. `%s`  %s  %s", i+1, slackEscape(formatName(result.Name)),
+			formatBandwidth(result.Bandwidth), formatMilliseconds(result.TTFB)))
+	}
+	blocks := []any{
+		map[string]any{"type": "header", "text": map[string]any{"type": "plain_text", "text": summary}},
+	}
+	if len(lines) > 0 {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": strings.Join(lines, "\n")},
+		})
+	}
+	return map[string]any{"text": summary, "blocks": blocks}
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "`", "'")
+
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// sendNotifications 将测试结果的摘要发送到全部 -notify webhook，单个 webhook 失败不影响其他的
+func sendNotifications(targets []notifyTarget, results []Result) []error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var errs []error
+	for _, target := range targets {
+		payload := discordPayload(results)
+		if target.Kind == "slack" {
+			payload = slackPayload(results)
+		}
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp, err := client.Post(target.URL, "application/json", bytes.NewReader(buf))
+		if err != nil {
+			// 错误信息中的地址包含 webhook 的密钥，不直接输出
+			errs = append(errs, fmt.Errorf("%s webhook: %w", target.Kind, unwrapURLError(err)))
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			errs = append(errs, fmt.Errorf("%s webhook: unexpected status %s", target.Kind, resp.Status))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"net"
+	"os"
+	"time"
+)
+
+// PingResult 为 ping 节点服务器的结果，Jitter 为 RTT 的标准差，Loss 为丢包率百分比
+type PingResult struct {
+	RTT    time.Duration
+	Jitter time.Duration
+	Loss   float64
+}
+
+// listenICMP 优先使用原始套接字，没有权限时退回到无特权的 UDP ICMP 套接字（需要 net.ipv4.ping_group_range 允许）
+func listenICMP(ip net.IP) (*icmp.PacketConn, bool, error) {
+	network, udpNetwork, address := "ip4:icmp", "udp4", "0.0.0.0"
+	if ip.To4() == nil {
+		network, udpNetwork, address = "ip6:ipv6-icmp", "udp6", "::"
+	}
+	if conn, err := icmp.ListenPacket(network, address); err == nil {
+		return conn, true, nil
+	}
+	conn, err := icmp.ListenPacket(udpNetwork, address)
+	if err != nil {
+		return nil, false, fmt.Errorf("listen icmp: %w", err)
+	}
+	return conn, false, nil
+}
+
+// TestPing 向节点服务器发送 count 个 ICMP echo 请求，返回平均 RTT 和丢包率
+func TestPing(proxy C.Proxy, count int, timeout time.Duration) (*PingResult, error) {
+	ip := resolveServerIP(proxy, timeout)
+	if ip == nil {
+		return nil, fmt.Errorf("resolve %s failed", proxy.Addr())
+	}
+	conn, privileged, err := listenICMP(ip)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	protocol := 1
+	if ip.To4() == nil {
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		protocol = 58
+	}
+
+	// 单个包最多等待 1 秒，避免 -timeout 较大时 ping 耗时过长
+	wait := timeout
+	if wait > time.Second {
+		wait = time.Second
+	}
+	id := os.Getpid() & 0xffff
+	var rtts []float64
+	buf := make([]byte, 1500)
+	for seq := 0; seq < count; seq++ {
+		msg := icmp.Message{
+			Type: requestType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("clash-speedtest")},
+		}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if _, err := conn.WriteTo(b, dst); err != nil {
+			return nil, err
+		}
+		_ = conn.SetReadDeadline(start.Add(wait))
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			reply, err := icmp.ParseMessage(protocol, buf[:n])
+			if err != nil || reply.Type != replyType {
+				continue
+			}
+			echo, ok := reply.Body.(*icmp.Echo)
+			// 无特权套接字的 ID 由内核改写，只能按序号匹配
+			if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+				continue
+			}
+			rtts = append(rtts, float64(time.Since(start)))
+			break
+		}
+	}
+
+	result := &PingResult{Loss: float64(count-len(rtts)) * 100 / float64(count), RTT: -1, Jitter: -1}
+	if len(rtts) > 0 {
+		rtt, jitter := meanStddev(rtts)
+		result.RTT, result.Jitter = time.Duration(rtt), time.Duration(jitter)
+	}
+	return result, nil
+}
+
+func formatPingLoss(ping *PingResult) string {
+	if ping == nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.0f%%", ping.Loss)
+}
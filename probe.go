@@ -0,0 +1,175 @@
+package main
+
+import (
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+	"net"
+	"time"
+)
+
+// nodeTester 保存一次运行中所有节点共用的测试参数和数据库
+type nodeTester struct {
+	livenessURLs []string
+	downloadSize int
+	timeout      time.Duration
+	geoip        *GeoIP
+	asnDB        *ASNDB
+	needExitIP   bool
+}
+
+// nodeProbe 为下载测试之后对节点的一项测试，alive 为 true 时只测试下载成功的节点，避免在失效节点上反复等待超时
+type nodeProbe struct {
+	alive   bool
+	enabled func(t *nodeTester) bool
+	run     func(t *nodeTester, name string, proxy C.Proxy, result *Result)
+}
+
+// nodeProbes 按顺序执行，地区、ASN 和 IP 风险依赖之前检测到的出口 IP
+var nodeProbes = []nodeProbe{
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return uploadURL != "" },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Upload = TestUpload(proxy, uploadURL, t.downloadSize, t.timeout)
+		},
+	},
+	{
+		// 证书不受信任时下载会失败，因此不论带宽都检查
+		enabled: func(t *nodeTester) bool { return *checkCert || *tlsInfo },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			state, err := probeTLS(proxy, t.livenessURLs[0], t.timeout)
+			if err == nil {
+				result.TLS, result.ALPN = tlsVersionName(state.Version), state.NegotiatedProtocol
+			}
+			if *checkCert {
+				result.Cert = CheckCert(name, state)
+			}
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *checkCompression },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Compress = CheckCompression(proxy, t.livenessURLs[0], t.timeout)
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *testHTTP3 },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			if proxy.SupportUDP() {
+				result.HTTP3 = TestHTTP3(proxy, t.livenessURLs[0], t.downloadSize, t.timeout)
+			}
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *latencyURL != "" },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Latency = TestLatency(proxy, *latencyURL, t.timeout)
+		},
+	},
+	{
+		// -server-rtt 和 -ping 由本机直连节点服务器测量，失效的节点也能得到结果
+		enabled: func(t *nodeTester) bool { return *serverRTT },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.ServerRTT = TestServerRTT(proxy, t.timeout)
+		},
+	},
+	{
+		enabled: func(t *nodeTester) bool { return *pingCount > 0 },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			ping, err := TestPing(proxy, *pingCount, t.timeout)
+			if err != nil {
+				log.Warnln("failed to ping %s: %s", name, err)
+			}
+			result.Ping = ping
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *dnsTime },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.DNS = TestDNS(proxy, t.timeout)
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *checkUDP },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.UDP = CheckUDP(proxy, t.timeout)
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return len(testTargets) > 0 },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			for _, target := range testTargets {
+				r := TestProxyConcurrent(name, proxy, target.URL, t.downloadSize, t.timeout, *concurrent)
+				result.Targets = append(result.Targets, TargetResult{Bandwidth: r.Bandwidth, TTFB: r.TTFB})
+			}
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return len(reachDomains) > 0 },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Reach = CheckReach(proxy, reachDomains, t.timeout)
+		},
+	},
+	{
+		// 出口 IP 需要通过节点检测，不可用的节点只会等待超时
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return t.needExitIP },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			if ip := detectExitIP(proxy, t.timeout); ip != nil {
+				result.ExitIP = ip.String()
+			}
+		},
+	},
+	{
+		// -geoip-source server 由本机解析节点服务器地址，失效的节点也能得到地区
+		enabled: func(t *nodeTester) bool { return t.geoip != nil && *geoipSource == "server" },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Country, result.City = t.geoip.Lookup(resolveServerIP(proxy, t.timeout))
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return t.geoip != nil && *geoipSource == "exit" },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.Country, result.City = t.geoip.Lookup(net.ParseIP(result.ExitIP))
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return t.asnDB != nil },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			result.ASN = t.asnDB.Lookup(net.ParseIP(result.ExitIP))
+		},
+	},
+	{
+		alive:   true,
+		enabled: func(t *nodeTester) bool { return *ipRiskProvider != "" },
+		run: func(t *nodeTester, name string, proxy C.Proxy, result *Result) {
+			if result.ExitIP == "" {
+				return
+			}
+			risk, err := lookupIPRisk(*ipRiskProvider, *ipRiskToken, result.ExitIP, t.timeout)
+			if err != nil {
+				log.Warnln("failed to lookup ip risk of %s: %s", name, err)
+			}
+			result.IPRisk = risk
+		},
+	},
+}
+
+// Probe 对下载测试完成的节点依次执行启用的 nodeProbes
+func (t *nodeTester) Probe(name string, proxy C.Proxy, result *Result) {
+	for _, probe := range nodeProbes {
+		if !probe.enabled(t) || (probe.alive && result.Bandwidth <= 0) {
+			continue
+		}
+		probe.run(t, name, proxy, result)
+	}
+}
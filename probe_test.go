@@ -0,0 +1,69 @@
+package main
+
+import (
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/oschwald/maxminddb-golang"
+	"testing"
+	"time"
+)
+
+// fakeProxy 只实现 Addr，调用其他方法说明测试通过了节点
+type fakeProxy struct {
+	C.Proxy
+	addr string
+}
+
+func (p fakeProxy) Addr() string { return p.addr }
+
+// testGeoIP 构造只有一个节点的 sing-geoip 数据库，所有 IPv4 地址都对应 code
+func testGeoIP(t *testing.T, code string) *GeoIP {
+	str := func(s string) []byte { return append([]byte{2<<5 | byte(len(s))}, s...) }
+	uint16 := func(v byte) []byte { return []byte{5<<5 | 1, v} }
+	// 左右两条记录都指向数据区的第一项：node_count + 16 + 0
+	db := []byte{0, 0, 17, 0, 0, 17}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, str(code)...)
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, 7<<5|6)
+	db = append(db, str("node_count")...)
+	db = append(db, 6<<5|1, 1)
+	db = append(db, str("record_size")...)
+	db = append(db, uint16(24)...)
+	db = append(db, str("ip_version")...)
+	db = append(db, uint16(4)...)
+	db = append(db, str("database_type")...)
+	db = append(db, str("sing-geoip")...)
+	db = append(db, str("binary_format_major_version")...)
+	db = append(db, uint16(2)...)
+	db = append(db, str("binary_format_minor_version")...)
+	db = append(db, uint16(0)...)
+	reader, err := maxminddb.FromBytes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GeoIP{reader: reader}
+}
+
+func TestProbeGeoIP(t *testing.T) {
+	defer func(source string) { *geoipSource = source }(*geoipSource)
+	tester := &nodeTester{timeout: time.Second, geoip: testGeoIP(t, "jp")}
+	tests := []struct {
+		source    string
+		bandwidth float64
+		exitIP    string
+		want      string
+	}{
+		{source: "server", bandwidth: 0, want: "JP"},
+		{source: "server", bandwidth: 1024, want: "JP"},
+		{source: "exit", bandwidth: 0, exitIP: "127.0.0.2", want: ""},
+		{source: "exit", bandwidth: 1024, exitIP: "127.0.0.2", want: "JP"},
+	}
+	for _, tt := range tests {
+		*geoipSource = tt.source
+		result := &Result{Name: "node", Bandwidth: tt.bandwidth, ExitIP: tt.exitIP}
+		tester.Probe("node", fakeProxy{addr: "127.0.0.1:443"}, result)
+		if result.Country != tt.want {
+			t.Errorf("source %s, bandwidth %.0f: country = %q, want %q", tt.source, tt.bandwidth, result.Country, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// progressEvent 为 -progress json 输出到标准错误的一行事件：
+// begin 为开始测试，start 为开始测试一个节点，done 为节点测试完成，end 为全部测试完成
+type progressEvent struct {
+	Event     string  `json:"event"`
+	Node      string  `json:"node,omitempty"`
+	Index     int     `json:"index,omitempty"` // 节点的序号，从 1 开始
+	Total     int     `json:"total,omitempty"`
+	Status    string  `json:"status,omitempty"`    // ok、failed、skipped 或 cached
+	Bandwidth float64 `json:"bandwidth,omitempty"` // 字节/秒
+	TTFB      int64   `json:"ttfb_ms,omitempty"`
+	Time      string  `json:"time"`
+}
+
+// progressReporter 在 -progress json 时输出节点测试的进度，为 nil 时不输出
+type progressReporter struct {
+	encoder *json.Encoder
+	total   int
+	index   int
+}
+
+func newProgressReporter(format string, total int) *progressReporter {
+	if format != "json" {
+		return nil
+	}
+	return &progressReporter{encoder: json.NewEncoder(os.Stderr), total: total}
+}
+
+func (p *progressReporter) emit(event progressEvent) {
+	event.Time = time.Now().Format(time.RFC3339)
+	_ = p.encoder.Encode(event)
+}
+
+func (p *progressReporter) Begin() {
+	if p == nil {
+		return
+	}
+	p.emit(progressEvent{Event: "begin", Total: p.total})
+}
+
+func (p *progressReporter) Start(name string) {
+	if p == nil {
+		return
+	}
+	p.index++
+	p.emit(progressEvent{Event: "start", Node: name, Index: p.index, Total: p.total})
+}
+
+// Done 输出节点的测试结果，status 为空时根据带宽判断是否可用
+func (p *progressReporter) Done(result *Result, status string) {
+	if p == nil {
+		return
+	}
+	if status == "" {
+		status = "failed"
+		if result.Bandwidth > 0 {
+			status = "ok"
+		}
+	}
+	event := progressEvent{Event: "done", Node: result.Name, Index: p.index, Total: p.total, Status: status}
+	if result.Bandwidth > 0 {
+		event.Bandwidth = result.Bandwidth
+		event.TTFB = result.TTFB.Milliseconds()
+	}
+	p.emit(event)
+}
+
+func (p *progressReporter) End() {
+	if p == nil {
+		return
+	}
+	p.emit(progressEvent{Event: "end", Total: p.total})
+}
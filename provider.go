@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Dreamacro/clash/common/convert"
+	C "github.com/Dreamacro/clash/constant"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renamedConfig 复制节点配置并替换节点名，避免修改原始配置
+func renamedConfig(config map[string]any, name string) map[string]any {
+	renamed := make(map[string]any, len(config))
+	for k, v := range config {
+		renamed[k] = v
+	}
+	renamed["name"] = name
+	return renamed
+}
+
+// providerSnippetPath 返回 provider 文件旁的配置片段路径，如 proxies.yaml -> proxies.snippet.yaml
+func providerSnippetPath(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".snippet" + ext
+}
+
+// writeProxyProvider 输出可被 proxy-providers 引用的节点文件，并在旁边生成带 health-check 的引用示例
+func writeProxyProvider(filePath string, results []Result, proxies map[string]CProxy) error {
+	var providerProxies []any
+	for _, result := range results {
+		proxy, ok := proxies[result.Name]
+		if !ok {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := outputName(config, result, len(providerProxies)+1)
+		providerProxies = append(providerProxies, renamedConfig(config, name))
+	}
+
+	buf, err := marshalProxies(map[string]any{"proxies": providerProxies}, results)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, buf, 0o644); err != nil {
+		return err
+	}
+
+	name := "speedtest"
+	snippet := map[string]any{
+		"proxy-providers": map[string]any{
+			name: map[string]any{
+				"type": "file",
+				"path": "./" + filepath.Base(filePath),
+				"health-check": map[string]any{
+					"enable":   true,
+					"url":      "https://www.gstatic.com/generate_204",
+					"interval": 300,
+				},
+			},
+		},
+		"proxy-groups": []any{
+			map[string]any{
+				"name":      name,
+				"type":      "url-test",
+				"use":       []string{name},
+				"url":       "https://www.gstatic.com/generate_204",
+				"interval":  300,
+				"tolerance": 50,
+			},
+		},
+	}
+	buf, err = yaml.Marshal(snippet)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(providerSnippetPath(filePath), buf, 0o644)
+}
+
+// providerProxyConfigs 读取 proxy-providers 在 Initial 后保存在本地的内容，返回按节点名索引的原始配置，
+// 使 provider 中的节点也能像 proxies 中的节点一样导出
+func providerProxyConfigs(config map[string]any) (map[string]map[string]any, error) {
+	vehicleType, _ := config["type"].(string)
+	path, _ := config["path"].(string)
+	switch {
+	case path != "":
+		path = C.Path.Resolve(path)
+	case vehicleType == "http":
+		// 与 Clash 相同，未指定 path 的 http provider 按 url 的哈希保存
+		url, _ := config["url"].(string)
+		path = C.Path.GetPathByHash("proxies", url)
+	default:
+		return nil, fmt.Errorf("provider has no path")
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(buf, &schema); err != nil || schema.Proxies == nil {
+		if schema.Proxies, err = convert.ConvertsV2Ray(buf); err != nil {
+			return nil, err
+		}
+	}
+	dialerProxy, _ := config["dialer-proxy"].(string)
+	configs := make(map[string]map[string]any, len(schema.Proxies))
+	for _, mapping := range schema.Proxies {
+		name, ok := mapping["name"].(string)
+		if !ok {
+			continue
+		}
+		if _, exist := configs[name]; exist {
+			continue
+		}
+		if dialerProxy != "" {
+			mapping["dialer-proxy"] = dialerProxy
+		}
+		configs[name] = mapping
+	}
+	return configs, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// quietStdout 为 -quiet 时原来的标准输出，只用于输出 -output 指定格式的结果，其余输出都被丢弃
+var quietStdout *os.File
+
+// enableQuiet 丢弃表格、进度等输出并关闭颜色，日志改为输出到标准错误
+func enableQuiet() error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	quietStdout = os.Stdout
+	os.Stdout = devNull
+	logrus.SetOutput(os.Stderr)
+	red, green = "", ""
+	return nil
+}
+
+// outputPath 返回 -output 写入的文件路径，-quiet 时写入临时目录，写完后由 emitOutput 输出到标准输出
+func outputPath() (string, error) {
+	if quietStdout == nil {
+		return *fileName, nil
+	}
+	dir, err := os.MkdirTemp("", "clash-speedtest")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(*fileName)), nil
+}
+
+// emitOutput 在 -quiet 时将写入的结果输出到标准输出并删除临时文件
+func emitOutput(path string) error {
+	if quietStdout == nil {
+		return nil
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	_, err = io.Copy(quietStdout, fp)
+	return err
+}
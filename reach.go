@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reachDomains 由 -reach-check 解析得到，顺序与参数一致
+var reachDomains []string
+
+// parseReachDomains 解析逗号分隔的域名，兼容带 https:// 前缀的写法
+func parseReachDomains(s string) ([]string, error) {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, domain := range strings.Split(s, ",") {
+		domain = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(domain), "https://"), "/")
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, "/?#") {
+			return nil, fmt.Errorf("invalid domain %s", domain)
+		}
+		if seen[domain] {
+			return nil, fmt.Errorf("duplicate domain %s", domain)
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// CheckReach 通过节点并发向每个域名发送 HTTPS HEAD 请求，收到任意响应即为 ok，
+// 连接失败、证书错误或超时为 blocked，结果顺序与 domains 一致
+func CheckReach(proxy C.Proxy, domains []string, timeout time.Duration) []string {
+	client := newProxyClient(proxy, timeout)
+	// 跳转到登录页或其他地区的站点也说明可以访问
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	reach := make([]string, len(domains))
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Add(1)
+		go func(i int, domain string) {
+			defer wg.Done()
+			reach[i] = "blocked"
+			req, err := http.NewRequest(http.MethodHead, "https://"+domain+"/", nil)
+			if err != nil {
+				return
+			}
+			applyTestHeaders(req)
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			reach[i] = "ok"
+		}(i, domain)
+	}
+	wg.Wait()
+	return reach
+}
+
+// reachColumnWidth 让列宽容纳较长的域名
+func reachColumnWidth(domain string) int {
+	if len(domain)+2 > 12 {
+		return len(domain) + 2
+	}
+	return 12
+}
@@ -0,0 +1,65 @@
+package main
+
+import "strings"
+
+const redactedValue = "******"
+
+// redactKeys 是导出时需要隐藏的凭据字段
+var redactKeys = map[string]bool{
+	"username":               true,
+	"password":               true,
+	"uuid":                   true,
+	"private-key":            true,
+	"private-key-passphrase": true,
+	"pre-shared-key":         true,
+	"psk":                    true,
+	"auth":                   true,
+	"auth-str":               true,
+	"auth_str":               true,
+	"obfs-password":          true,
+	"protocol-param":         true,
+	"short-id":               true,
+	"token":                  true,
+	"authorization":          true,
+	"proxy-authorization":    true,
+}
+
+// redactValue 递归复制配置，并将凭据字段替换为 ******
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, value := range v {
+			if redactKeys[strings.ToLower(key)] {
+				redacted[key] = redactedValue
+				continue
+			}
+			redacted[key] = redactValue(value)
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for key, value := range v {
+			if redactKeys[strings.ToLower(key)] {
+				value = redactedValue
+			}
+			redacted[key] = value
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactValue(value)
+		}
+		return redacted
+	}
+	return v
+}
+
+// redactProxies 隐藏所有节点配置中的凭据，导出的结果可以公开分享
+func redactProxies(proxies map[string]CProxy) {
+	for name, proxy := range proxies {
+		proxy.SecretConfig = redactValue(proxy.SecretConfig)
+		proxies[name] = proxy
+	}
+}
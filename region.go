@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var countryNameRegexes = []struct {
+	Code  string
+	Regex *regexp.Regexp
+}{
+	{"HK", regexp.MustCompile(`(?i)港|\bHK\b|hong\s?kong|🇭🇰`)},
+	{"TW", regexp.MustCompile(`(?i)台|\bTW\b|taiwan|🇹🇼`)},
+	{"JP", regexp.MustCompile(`(?i)日本|东京|大阪|\bJP\b|japan|tokyo|osaka|🇯🇵`)},
+	{"SG", regexp.MustCompile(`(?i)新加坡|狮城|\bSG\b|singapore|🇸🇬`)},
+	{"KR", regexp.MustCompile(`(?i)韩|首尔|\bKR\b|korea|seoul|🇰🇷`)},
+	{"US", regexp.MustCompile(`(?i)美国|洛杉矶|硅谷|西雅图|\bUSA?\b|united\s?states|america|🇺🇸`)},
+	{"GB", regexp.MustCompile(`(?i)英国|伦敦|\bUK\b|\bGB\b|united\s?kingdom|london|🇬🇧`)},
+	{"DE", regexp.MustCompile(`(?i)德国|法兰克福|\bDE\b|germany|frankfurt|🇩🇪`)},
+	{"FR", regexp.MustCompile(`(?i)法国|巴黎|\bFR\b|france|paris|🇫🇷`)},
+	{"NL", regexp.MustCompile(`(?i)荷兰|\bNL\b|netherlands|amsterdam|🇳🇱`)},
+	{"CA", regexp.MustCompile(`(?i)加拿大|\bCA\b|canada|🇨🇦`)},
+	{"AU", regexp.MustCompile(`(?i)澳大利亚|澳洲|悉尼|\bAU\b|australia|sydney|🇦🇺`)},
+	{"RU", regexp.MustCompile(`(?i)俄罗斯|\bRU\b|russia|moscow|🇷🇺`)},
+	{"IN", regexp.MustCompile(`(?i)印度|\bIN\b|india|🇮🇳`)},
+	{"TR", regexp.MustCompile(`(?i)土耳其|\bTR\b|turkey|türkiye|🇹🇷`)},
+}
+
+// guessCountry 根据节点名猜测国家代码，识别不出时返回空
+func guessCountry(name string) string {
+	for _, item := range countryNameRegexes {
+		if item.Regex.MatchString(name) {
+			return item.Code
+		}
+	}
+	return ""
+}
+
+// nodeCountry 优先使用 GeoIP 的结果，没有时根据节点名猜测
+func nodeCountry(result Result) string {
+	if result.Country != "" {
+		return result.Country
+	}
+	return guessCountry(result.Name)
+}
+
+// countryFlag 将两位国家代码转换为国旗 emoji
+func countryFlag(code string) string {
+	code = strings.ToUpper(code)
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "🌐"
+	}
+	return string([]rune{rune(code[0]) - 'A' + 0x1F1E6, rune(code[1]) - 'A' + 0x1F1E6})
+}
+
+type regionMember struct {
+	Name    string
+	Country string
+}
+
+// regionProxyGroups 为每个国家生成一个 url-test 分组，再用一个 select 分组汇总
+func regionProxyGroups(members []regionMember) []any {
+	regions := make(map[string][]string)
+	for _, member := range members {
+		regions[member.Country] = append(regions[member.Country], member.Name)
+	}
+	codes := make([]string, 0, len(regions))
+	for code := range regions {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		// 未识别的地区放在最后
+		if codes[i] == "" || codes[j] == "" {
+			return codes[j] == ""
+		}
+		return codes[i] < codes[j]
+	})
+
+	groups := make([]any, 0, len(codes)+1)
+	names := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name := countryFlag(code) + " " + code
+		if code == "" {
+			name = "🌐 其他"
+		}
+		names = append(names, name)
+		groups = append(groups, map[string]any{
+			"name":      name,
+			"type":      "url-test",
+			"url":       "https://www.gstatic.com/generate_204",
+			"interval":  300,
+			"tolerance": 50,
+			"proxies":   regions[code],
+		})
+	}
+	selector := map[string]any{
+		"name":    "🚀 节点选择",
+		"type":    "select",
+		"proxies": names,
+	}
+	return append([]any{selector}, groups...)
+}
+
+// writeSplitByCountry 按国家将通过测试的节点分别写入 dir 下的 HK.yaml、JP.yaml 等文件，识别不出国家的写入 OTHER.yaml，
+// 同时将全部节点写入 ALL.yaml
+func writeSplitByCountry(dir string, results []Result, proxies map[string]CProxy) error {
+	regions := make(map[string][]any)
+	var all []any
+	for _, result := range results {
+		proxy, ok := proxies[result.Name]
+		if !ok {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		code := nodeCountry(result)
+		if code == "" {
+			code = "OTHER"
+		}
+		name := outputName(config, result, len(regions[code])+1)
+		regions[code] = append(regions[code], renamedConfig(config, name))
+		all = append(all, renamedConfig(config, outputName(config, result, len(all)+1)))
+	}
+	regions["ALL"] = all
+
+	for code, nodes := range regions {
+		buf, err := marshalProxies(map[string]any{"proxies": nodes}, results)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, code+".yaml"), buf, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// bandwidthSuffixRegex 匹配 formatBandwidthSuffix 追加的带宽后缀
+var bandwidthSuffixRegex = regexp.MustCompile(`(-\d+(GBPS|MBPS|MB/s|Mbps))+$`)
+
+// stripBandwidthSuffix 去掉节点名中已有的带宽后缀，避免重复导出或 -in-place 多次运行时后缀越叠越长
+func stripBandwidthSuffix(name string) string {
+	return bandwidthSuffixRegex.ReplaceAllString(name, "")
+}
+
+// renameTemplate 由 -rename 解析得到，为 nil 时使用默认的带宽后缀
+var renameTemplate *template.Template
+
+type RenameData struct {
+	Name          string
+	Type          string
+	Country       string
+	City          string
+	ExitIP        string
+	Index         int
+	BandwidthMbps int
+	BandwidthMBps int
+	TTFBms        int64
+}
+
+// outputName 计算导出时的节点名，index 从 1 开始，开启 -annotate 时保留原名
+func outputName(config map[string]any, result Result, index int) string {
+	if *annotate {
+		return configString(config, "name")
+	}
+	name := renameNode(config, result, index)
+	if *flagNames && result.Country != "" {
+		name = countryFlag(result.Country) + " " + strings.TrimSpace(emojiRegex.ReplaceAllString(name, ""))
+	}
+	return name
+}
+
+func renameNode(config map[string]any, result Result, index int) string {
+	name := stripBandwidthSuffix(configString(config, "name"))
+	if renameTemplate == nil {
+		return fmt.Sprintf("%s%s", name, formatBandwidthSuffix(result.Bandwidth))
+	}
+	data := RenameData{
+		Name:          name,
+		Type:          configString(config, "type"),
+		Country:       nodeCountry(result),
+		City:          result.City,
+		ExitIP:        result.ExitIP,
+		Index:         index,
+		BandwidthMbps: int(result.Bandwidth * 8 / 1000 / 1000),
+		BandwidthMBps: int(result.Bandwidth / bandwidthBase / bandwidthBase),
+		TTFBms:        result.TTFB.Milliseconds(),
+	}
+	var sb strings.Builder
+	if err := renameTemplate.Execute(&sb, data); err != nil {
+		log.Warnln("failed to rename %s: %s", name, err)
+		return fmt.Sprintf("%s%s", name, formatBandwidthSuffix(result.Bandwidth))
+	}
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Dreamacro/clash/component/resolver"
+	"github.com/Dreamacro/clash/dns"
+	"github.com/Dreamacro/clash/log"
+	"gopkg.in/yaml.v3"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// configDNS 为 Clash 配置中 dns 段落里与解析相关的部分，enhanced-mode 等只对 Clash 自身的入站生效，这里不需要
+type configDNS struct {
+	Enable                bool     `yaml:"enable"`
+	IPv6                  bool     `yaml:"ipv6"`
+	DefaultNameserver     []string `yaml:"default-nameserver"`
+	Nameserver            []string `yaml:"nameserver"`
+	Fallback              []string `yaml:"fallback"`
+	ProxyServerNameserver []string `yaml:"proxy-server-nameserver"`
+}
+
+// applyConfigDNS 使用配置中 dns 段落的 nameserver 替换系统 DNS 解析节点和测试地址，
+// 返回是否启用；配置中没有启用 dns 时保持使用系统 DNS
+func applyConfigDNS(buf []byte) (bool, error) {
+	var rawCfg struct {
+		DNS configDNS `yaml:"dns"`
+	}
+	if err := yaml.Unmarshal(buf, &rawCfg); err != nil || !rawCfg.DNS.Enable || len(rawCfg.DNS.Nameserver) == 0 {
+		return false, nil
+	}
+	cfg := rawCfg.DNS
+
+	nameserver, err := parseNameServers(cfg.Nameserver)
+	if err != nil {
+		return false, err
+	}
+	fallback, err := parseNameServers(cfg.Fallback)
+	if err != nil {
+		return false, err
+	}
+	proxyServer, err := parseNameServers(cfg.ProxyServerNameserver)
+	if err != nil {
+		return false, err
+	}
+	// 与 Clash 一致，未配置 default-nameserver 时使用公共 DNS 解析 DoH 等服务器的域名
+	if len(cfg.DefaultNameserver) == 0 {
+		cfg.DefaultNameserver = []string{"114.114.114.114", "223.5.5.5", "8.8.8.8"}
+	}
+	defaultNS, err := parseNameServers(cfg.DefaultNameserver)
+	if err != nil {
+		return false, err
+	}
+
+	r := dns.NewResolver(dns.Config{
+		Main:        nameserver,
+		Fallback:    fallback,
+		Default:     defaultNS,
+		ProxyServer: proxyServer,
+		IPv6:        cfg.IPv6,
+	})
+	resolver.DefaultResolver = r
+	// 与 Clash 相同，只在配置了 proxy-server-nameserver 时单独解析节点地址（Invalid 返回 true 表示可用）
+	if pr := dns.NewProxyServerHostResolver(r); pr.Invalid() {
+		resolver.ProxyServerHostResolver = pr
+	}
+	return true, nil
+}
+
+// parseNameServers 解析 nameserver 地址，写法与 Clash 相同：8.8.8.8、tcp://、tls://、https://、quic:// 和 system
+func parseNameServers(servers []string) ([]dns.NameServer, error) {
+	var nameservers []dns.NameServer
+	for _, server := range servers {
+		if server == "system" {
+			server = "system://"
+		} else if ip, err := netip.ParseAddr(server); err == nil {
+			server = "udp://" + net.JoinHostPort(ip.String(), "53")
+		} else if !strings.Contains(server, "://") {
+			server = "udp://" + server
+		}
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nameserver %s: %w", server, err)
+		}
+
+		var ns dns.NameServer
+		switch u.Scheme {
+		case "udp":
+			ns.Addr = hostWithDefaultPort(u.Host, "53")
+		case "tcp":
+			ns.Net, ns.Addr = "tcp", hostWithDefaultPort(u.Host, "53")
+		case "tls":
+			ns.Net, ns.Addr = "tcp-tls", hostWithDefaultPort(u.Host, "853")
+		case "https":
+			// 地址后的 #节点名 表示通过节点查询，测速时不使用
+			ns.Net = "https"
+			ns.Addr = (&url.URL{Scheme: "https", Host: hostWithDefaultPort(u.Host, "443"), Path: u.Path, User: u.User}).String()
+		case "quic":
+			ns.Net, ns.Addr = "quic", hostWithDefaultPort(u.Host, "853")
+		case "system":
+			ns.Net = "system"
+		default:
+			return nil, fmt.Errorf("unsupported nameserver %s", server)
+		}
+		nameservers = append(nameservers, ns)
+	}
+	return nameservers, nil
+}
+
+func hostWithDefaultPort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// pinServerIPs 在测试前解析节点的服务器域名，并通过 hosts 固定为解析结果，避免 DNS 轮询让多次测量连到不同的服务器；
+// 返回节点名到服务器 IP 的对应关系，用于显示
+func pinServerIPs(names []string, proxies map[string]CProxy, timeout time.Duration) map[string]string {
+	ips := make(map[string]string, len(names))
+	pinned := make(map[string]netip.Addr)
+	for _, name := range names {
+		host, _, err := net.SplitHostPort(proxies[name].Addr())
+		if err != nil {
+			continue
+		}
+		if ip, err := netip.ParseAddr(host); err == nil {
+			ips[name] = ip.String()
+			continue
+		}
+		ip, ok := pinned[host]
+		if !ok {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			ip, err = resolver.ResolveProxyServerHost(ctx, host)
+			cancel()
+			if err != nil {
+				log.Warnln("failed to resolve %s: %s", host, err)
+			} else if value, err := resolver.NewHostValueByIPs([]netip.Addr{ip}); err == nil {
+				_ = resolver.DefaultHosts.Insert(host, value)
+			}
+			// 解析失败时同样记录，同一域名不再重复解析
+			pinned[host] = ip
+		}
+		if ip.IsValid() {
+			ips[name] = ip.String()
+		}
+	}
+	return ips
+}
+
+func formatServerIP(ip string) string {
+	if ip == "" {
+		return "N/A"
+	}
+	return ip
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	C "github.com/Dreamacro/clash/constant"
+	"net"
+	"time"
+)
+
+// TestServerRTT 测量本机直连节点服务器端口的 TCP 握手耗时，与经过节点的延迟对比可区分服务器距离远和落地绕路，
+// Hysteria、TUIC 等基于 UDP 的节点无法测量，返回 -1
+func TestServerRTT(proxy C.Proxy, timeout time.Duration) time.Duration {
+	switch proxy.Type() {
+	case C.Hysteria, C.Hysteria2, C.Tuic, C.WireGuard:
+		return -1
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", proxy.Addr(), timeout)
+	if err != nil {
+		return -1
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// progressWriter 丢弃写入的数据，只累加字节数，用于下载过程中按秒采样
+type progressWriter struct {
+	n *int64
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	if w.n != nil {
+		atomic.AddInt64(w.n, int64(len(p)))
+	}
+	return len(p), nil
+}
+
+// sampleThroughput 每秒记录一次 downloaded 的增量，直到 done 被关闭，返回每秒的下载速度（字节/秒）
+func sampleThroughput(downloaded *int64, done <-chan struct{}) []float64 {
+	var samples []float64
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := int64(0)
+	lastTime := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			current := atomic.LoadInt64(downloaded)
+			samples = append(samples, float64(current-last))
+			last, lastTime = current, time.Now()
+		case <-done:
+			// 最后不足一秒的部分按实际时长折算，过短时误差太大直接丢弃，除非整个下载不足一秒
+			if elapsed := time.Since(lastTime); elapsed >= 100*time.Millisecond || (len(samples) == 0 && elapsed > 0) {
+				samples = append(samples, float64(atomic.LoadInt64(downloaded)-last)/elapsed.Seconds())
+			}
+			return samples
+		}
+	}
+}
+
+var sampleFileNameReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+
+// writeSamples 将节点每秒的下载速度写入 dir 下以节点名命名的 csv 文件
+func writeSamples(dir string, name string, samples []float64) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	fp, err := os.Create(filepath.Join(dir, sampleFileNameReplacer.Replace(name)+".csv"))
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	writer := csv.NewWriter(fp)
+	if err := writer.Write([]string{"second", "bytes_per_second"}); err != nil {
+		return err
+	}
+	for i, v := range samples {
+		if err := writer.Write([]string{fmt.Sprint(i + 1), fmt.Sprintf("%.0f", v)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Throttle 为从速度曲线中检测到的限速，After 为开始限速前已下载的字节数，Rate 为限速后的平均速度
+type Throttle struct {
+	After int64
+	Rate  float64
+}
+
+// throttleRatio 限速后的平均速度低于之前的该比例时认为节点被限速
+const throttleRatio = 0.5
+
+// detectThrottle 寻找速度曲线中前后平均速度差异最大的分割点，后段明显变慢时返回限速信息，
+// 前后两段至少各有 2 秒的数据
+func detectThrottle(samples []float64) *Throttle {
+	// 跳过等待首字节的空白采样
+	for len(samples) > 0 && samples[0] == 0 {
+		samples = samples[1:]
+	}
+	if len(samples) < 4 {
+		return nil
+	}
+
+	total := 0.0
+	for _, v := range samples {
+		total += v
+	}
+	var best *Throttle
+	bestRatio := throttleRatio
+	before := 0.0
+	for i := 1; i < len(samples); i++ {
+		before += samples[i-1]
+		if i < 2 || len(samples)-i < 2 {
+			continue
+		}
+		beforeRate := before / float64(i)
+		afterRate := (total - before) / float64(len(samples)-i)
+		if beforeRate <= 0 {
+			continue
+		}
+		// 后段中出现接近前段速度的采样时不是限速，而是普通的波动
+		recovered := false
+		for _, v := range samples[i:] {
+			if v > beforeRate*throttleRatio {
+				recovered = true
+				break
+			}
+		}
+		if ratio := afterRate / beforeRate; !recovered && ratio < bestRatio {
+			bestRatio = ratio
+			best = &Throttle{After: int64(before), Rate: afterRate}
+		}
+	}
+	return best
+}
+
+func formatThrottle(throttle *Throttle) string {
+	if throttle == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s@%dMB", formatBandwidth(throttle.Rate), throttle.After/1024/1024)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparklineWidth 为速度曲线的最大宽度，采样更多时合并相邻的采样
+const sparklineWidth = 20
+
+// sparkline 将每秒的下载速度绘制为一行 unicode 方块，高度按最大速度缩放
+func sparkline(samples []float64) string {
+	if len(samples) == 0 {
+		return "N/A"
+	}
+	if len(samples) > sparklineWidth {
+		merged := make([]float64, sparklineWidth)
+		for i := range merged {
+			from, to := i*len(samples)/sparklineWidth, (i+1)*len(samples)/sparklineWidth
+			for _, v := range samples[from:to] {
+				merged[i] += v
+			}
+			merged[i] /= float64(to - from)
+		}
+		samples = merged
+	}
+	peak := 0.0
+	for _, v := range samples {
+		if v > peak {
+			peak = v
+		}
+	}
+	line := make([]rune, len(samples))
+	for i, v := range samples {
+		level := 0
+		if peak > 0 {
+			level = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
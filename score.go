@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultScoreWeights 为未指定 -score-weights 时的权重
+const defaultScoreWeights = "bw=0.5,ttfb=0.3,jitter=0.2"
+
+// scoreWeights 为 nil 时不计算综合评分
+var scoreWeights map[string]float64
+
+// scoreMetric 从结果中取出用于评分的指标，ok 为 false 表示该节点没有这项数据；
+// lowerBetter 为 true 时数值越小越好
+type scoreMetric struct {
+	value       func(r *Result) (float64, bool)
+	lowerBetter bool
+}
+
+var scoreMetrics = map[string]scoreMetric{
+	"bw":     {value: func(r *Result) (float64, bool) { return r.Bandwidth, r.Bandwidth > 0 }},
+	"ttfb":   {value: func(r *Result) (float64, bool) { return float64(r.TTFB), r.TTFB > 0 }, lowerBetter: true},
+	"upload": {value: func(r *Result) (float64, bool) { return r.Upload, r.Upload > 0 }},
+	"jitter": {value: func(r *Result) (float64, bool) {
+		if r.Ping == nil || r.Ping.Jitter < 0 {
+			return 0, false
+		}
+		return float64(r.Ping.Jitter), true
+	}, lowerBetter: true},
+	"loss": {value: func(r *Result) (float64, bool) {
+		if r.Ping == nil {
+			return 0, false
+		}
+		return r.Ping.Loss, true
+	}, lowerBetter: true},
+}
+
+// parseScoreWeights 解析 bw=0.5,ttfb=0.3 格式的评分权重
+func parseScoreWeights(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q, should be name=weight", item)
+		}
+		if _, ok := scoreMetrics[name]; !ok {
+			return nil, fmt.Errorf("unknown metric %q, supported: bw, ttfb, upload, jitter, loss", name)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q", item)
+		}
+		weights[name] = weight
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no weights specified")
+	}
+	return weights, nil
+}
+
+// computeScores 将各项指标在所有可用节点间归一化到 0-1 后按权重求和，得到 0-100 的综合评分；
+// 所有节点都没有数据的指标（如未指定 -ping 时的 jitter）不参与评分，不可用的节点为 0 分
+func computeScores(results []Result, weights map[string]float64) {
+	type bounds struct{ min, max float64 }
+	ranges := make(map[string]bounds)
+	for name := range weights {
+		metric := scoreMetrics[name]
+		b, found := bounds{}, false
+		for i := range results {
+			if results[i].Bandwidth <= 0 {
+				continue
+			}
+			v, ok := metric.value(&results[i])
+			if !ok {
+				continue
+			}
+			if !found {
+				b, found = bounds{v, v}, true
+			}
+			if v < b.min {
+				b.min = v
+			}
+			if v > b.max {
+				b.max = v
+			}
+		}
+		if found {
+			ranges[name] = b
+		}
+	}
+
+	totalWeight := 0.0
+	for name := range ranges {
+		totalWeight += weights[name]
+	}
+	for i := range results {
+		result := &results[i]
+		result.Score = 0
+		if result.Bandwidth <= 0 || totalWeight == 0 {
+			continue
+		}
+		score := 0.0
+		for name, b := range ranges {
+			v, ok := scoreMetrics[name].value(result)
+			if !ok {
+				continue
+			}
+			normalized := 1.0
+			if b.max > b.min {
+				normalized = (v - b.min) / (b.max - b.min)
+				if scoreMetrics[name].lowerBetter {
+					normalized = 1 - normalized
+				}
+			}
+			score += weights[name] * normalized
+		}
+		result.Score = score / totalWeight * 100
+	}
+}
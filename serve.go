@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bestProxy 按排名保存可用节点，当前节点连接失败时自动切换到下一个
+type bestProxy struct {
+	mu      sync.Mutex
+	names   []string
+	proxies map[string]CProxy
+	current int
+	auth    string // 为 user:pass 时要求客户端认证
+}
+
+func (b *bestProxy) dial(ctx context.Context, addr string) (net.Conn, error) {
+	b.mu.Lock()
+	start := b.current
+	b.mu.Unlock()
+
+	var lastErr error
+	for i := start; i < len(b.names); i++ {
+		name := b.names[i]
+		conn, err := dialProxy(ctx, b.proxies[name].Proxy, addr)
+		if err == nil {
+			b.mu.Lock()
+			if i > b.current {
+				log.Warnln("switch to %s", name)
+				b.current = i
+			}
+			b.mu.Unlock()
+			return conn, nil
+		}
+		log.Warnln("%s failed to connect %s: %s", name, addr, err)
+		lastErr = err
+	}
+	// 全部节点都失败时从头开始，下次连接重新尝试排名靠前的节点
+	b.mu.Lock()
+	b.current = 0
+	b.mu.Unlock()
+	return nil, lastErr
+}
+
+// serveBestProxy 在 addr 上提供同时支持 HTTP 和 SOCKS5 的代理，流量转发到排名最靠前的可用节点，auth 不为空时要求认证
+func serveBestProxy(addr string, auth string, results []Result, proxies map[string]CProxy) error {
+	best := &bestProxy{proxies: proxies, auth: auth}
+	for _, result := range results {
+		if _, ok := proxies[result.Name]; ok {
+			best.names = append(best.names, result.Name)
+		}
+	}
+	if len(best.names) == 0 {
+		return fmt.Errorf("no available proxy")
+	}
+
+	addr, err := listenAddr(addr, auth, "-serve-auth")
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n已在 %s 上提供 HTTP/SOCKS5 代理，当前节点 %s\n", listener.Addr(), best.names[0])
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := best.handle(conn); err != nil {
+				log.Debugln("serve %s: %s", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (b *bestProxy) handle(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	head, err := reader.Peek(1)
+	if err != nil {
+		return err
+	}
+	if head[0] == 0x05 {
+		return b.handleSocks5(conn, reader)
+	}
+	return b.handleHTTP(conn, reader)
+}
+
+func (b *bestProxy) handleHTTP(conn net.Conn, reader *bufio.Reader) error {
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return err
+	}
+	if b.auth != "" {
+		user, pass, ok := parseProxyAuthorization(req.Header.Get("Proxy-Authorization"))
+		if !ok || !checkAuth(b.auth, user, pass) {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"clash-speedtest\"\r\n\r\n")
+			return fmt.Errorf("proxy authentication failed")
+		}
+	}
+	addr := req.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "80")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	remote, err := b.dial(ctx, addr)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return err
+	}
+	defer remote.Close()
+
+	if req.Method == http.MethodConnect {
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return err
+		}
+	} else {
+		// 普通 HTTP 请求只转发这一个请求，避免复用连接访问其他域名
+		req.Header.Del("Proxy-Connection")
+		req.Header.Del("Proxy-Authorization")
+		req.Header.Set("Connection", "close")
+		if err := req.Write(remote); err != nil {
+			return err
+		}
+	}
+	relay(conn, reader, remote)
+	return nil
+}
+
+// parseProxyAuthorization 解析 Basic 认证的 Proxy-Authorization 请求头
+func parseProxyAuthorization(header string) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+func (b *bestProxy) handleSocks5(conn net.Conn, reader *bufio.Reader) error {
+	// 握手：VER NMETHODS METHODS，指定 -serve-auth 时只接受用户名密码认证，否则只接受无认证
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, methods); err != nil {
+		return err
+	}
+	method := byte(0x00)
+	if b.auth != "" {
+		method = 0x02
+	}
+	if !bytes.Contains(methods, []byte{method}) {
+		_, _ = conn.Write([]byte{0x05, 0xff})
+		return fmt.Errorf("no acceptable socks5 auth method")
+	}
+	if _, err := conn.Write([]byte{0x05, method}); err != nil {
+		return err
+	}
+	if method == 0x02 {
+		if err := b.socks5Auth(conn, reader); err != nil {
+			return err
+		}
+	}
+
+	// 请求：VER CMD RSV ATYP DST.ADDR DST.PORT
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return err
+	}
+	if request[1] != 0x01 {
+		_, _ = conn.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return fmt.Errorf("unsupported socks5 command %d", request[1])
+	}
+	var host string
+	switch request[3] {
+	case 0x01, 0x04:
+		ip := make([]byte, net.IPv4len)
+		if request[3] == 0x04 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return err
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		length, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		domain := make([]byte, length)
+		if _, err := io.ReadFull(reader, domain); err != nil {
+			return err
+		}
+		host = string(domain)
+	default:
+		return fmt.Errorf("unsupported socks5 address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	remote, err := b.dial(ctx, addr)
+	if err != nil {
+		_, _ = conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	defer remote.Close()
+	if _, err := conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	relay(conn, reader, remote)
+	return nil
+}
+
+// socks5Auth 处理 RFC 1929 用户名密码认证：VER ULEN UNAME PLEN PASSWD
+func (b *bestProxy) socks5Auth(conn net.Conn, reader *bufio.Reader) error {
+	readField := func() (string, error) {
+		length, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		field := make([]byte, length)
+		if _, err := io.ReadFull(reader, field); err != nil {
+			return "", err
+		}
+		return string(field), nil
+	}
+	if _, err := reader.ReadByte(); err != nil {
+		return err
+	}
+	user, err := readField()
+	if err != nil {
+		return err
+	}
+	pass, err := readField()
+	if err != nil {
+		return err
+	}
+	if !checkAuth(b.auth, user, pass) {
+		_, _ = conn.Write([]byte{0x01, 0x01})
+		return fmt.Errorf("socks5 authentication failed")
+	}
+	_, err = conn.Write([]byte{0x01, 0x00})
+	return err
+}
+
+// relay 双向转发数据，reader 中可能还有已经读取但未处理的数据
+func relay(conn net.Conn, reader io.Reader, remote net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(remote, reader)
+		_ = remote.Close()
+		close(done)
+	}()
+	_, _ = io.Copy(conn, remote)
+	_ = conn.Close()
+	<-done
+}
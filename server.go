@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// runServer 实现 server 子命令，提供下载测速数据和上传接收接口，返回进程退出码
+func runServer(args []string) int {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	listen := fs.String("l", ":8080", "listen address")
+	maxBytes := fs.Int64("max-bytes", 1<<30, "max bytes per download or upload request")
+	_ = fs.Parse(args)
+
+	// 随机数据无法被压缩，避免中间设备压缩后测出虚高的带宽；数据由随机种子生成，客户端可以据此校验
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	payload := payloadBlock(seed)
+	seedHex := hex.EncodeToString(seed)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<h1>SpeedTest Works</h1>`))
+	})
+	mux.HandleFunc("/liveness", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	down := func(w http.ResponseWriter, r *http.Request) {
+		size, err := strconv.ParseInt(r.URL.Query().Get("bytes"), 10, 64)
+		if err != nil || size < 0 || size > *maxBytes {
+			http.Error(w, fmt.Sprintf("bytes should be between 0 and %d", *maxBytes), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set(payloadSeedHeader, seedHex)
+		for size > 0 {
+			n := int64(len(payload))
+			if size < n {
+				n = size
+			}
+			if _, err := w.Write(payload[:n]); err != nil {
+				return
+			}
+			size -= n
+		}
+	}
+	up := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		n, err := io.Copy(io.Discard, io.LimitReader(r.Body, *maxBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"bytes":%d}`, n)
+	}
+	// 同时兼容 Cloudflare 的 __down 和 livenessObject 的 _down
+	mux.HandleFunc("/__down", down)
+	mux.HandleFunc("/_down", down)
+	mux.HandleFunc("/__up", up)
+	mux.HandleFunc("/_up", up)
+
+	fmt.Printf("speedtest server listening on %s, use http://<ip>%s/__down?bytes=%%d as -l\n", *listen, *listen)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
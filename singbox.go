@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type singBoxTLS struct {
+	Enabled    bool     `json:"enabled"`
+	ServerName string   `json:"server_name"`
+	Insecure   bool     `json:"insecure"`
+	ALPN       []string `json:"alpn"`
+	UTLS       struct {
+		Enabled     bool   `json:"enabled"`
+		Fingerprint string `json:"fingerprint"`
+	} `json:"utls"`
+	Reality struct {
+		Enabled   bool   `json:"enabled"`
+		PublicKey string `json:"public_key"`
+		ShortID   string `json:"short_id"`
+	} `json:"reality"`
+}
+
+type singBoxTransport struct {
+	Type        string            `json:"type"`
+	Path        string            `json:"path"`
+	Headers     map[string]string `json:"headers"`
+	ServiceName string            `json:"service_name"`
+}
+
+type singBoxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+
+	Method            string   `json:"method"`
+	Password          string   `json:"password"`
+	Plugin            string   `json:"plugin"`
+	PluginOpts        string   `json:"plugin_opts"`
+	UUID              string   `json:"uuid"`
+	Security          string   `json:"security"`
+	AlterID           int      `json:"alter_id"`
+	Flow              string   `json:"flow"`
+	Username          string   `json:"username"`
+	UpMbps            int      `json:"up_mbps"`
+	DownMbps          int      `json:"down_mbps"`
+	AuthStr           string   `json:"auth_str"`
+	CongestionControl string   `json:"congestion_control"`
+	UDPRelayMode      string   `json:"udp_relay_mode"`
+	LocalAddress      []string `json:"local_address"`
+	PrivateKey        string   `json:"private_key"`
+	PeerPublicKey     string   `json:"peer_public_key"`
+	PreSharedKey      string   `json:"pre_shared_key"`
+	MTU               int      `json:"mtu"`
+
+	// hysteria 的 obfs 是字符串，hysteria2 的 obfs 是对象
+	Obfs json.RawMessage `json:"obfs"`
+
+	TLS       *singBoxTLS       `json:"tls"`
+	Transport *singBoxTransport `json:"transport"`
+}
+
+// isSingBoxConfig 判断内容是否为包含 outbounds 的 sing-box JSON 配置
+func isSingBoxConfig(buf []byte) bool {
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 || buf[0] != '{' {
+		return false
+	}
+	var config struct {
+		Outbounds []json.RawMessage `json:"outbounds"`
+	}
+	return json.Unmarshal(buf, &config) == nil && len(config.Outbounds) > 0
+}
+
+// parseSingBoxOutbounds 将 sing-box 的 outbounds 转换为 Clash 节点配置，不支持的类型（selector、direct 等）会被跳过
+func parseSingBoxOutbounds(buf []byte) ([]map[string]any, error) {
+	var config struct {
+		Outbounds []singBoxOutbound `json:"outbounds"`
+	}
+	if err := json.Unmarshal(buf, &config); err != nil {
+		return nil, err
+	}
+
+	proxies := make([]map[string]any, 0, len(config.Outbounds))
+	for _, outbound := range config.Outbounds {
+		proxy := map[string]any{
+			"name":   outbound.Tag,
+			"server": outbound.Server,
+			"port":   outbound.ServerPort,
+		}
+		sniKey := "sni"
+		switch outbound.Type {
+		case "shadowsocks":
+			proxy["type"] = "ss"
+			proxy["cipher"] = outbound.Method
+			proxy["password"] = outbound.Password
+			if outbound.Plugin != "" {
+				plugin, opts, err := singBoxPlugin(outbound.Plugin, outbound.PluginOpts)
+				if err != nil {
+					log.Warnln("skip sing-box outbound %s: %s", outbound.Tag, err)
+					continue
+				}
+				proxy["plugin"] = plugin
+				proxy["plugin-opts"] = opts
+			}
+		case "vmess":
+			proxy["type"] = "vmess"
+			proxy["uuid"] = outbound.UUID
+			proxy["alterId"] = outbound.AlterID
+			proxy["cipher"] = outbound.Security
+			if outbound.Security == "" {
+				proxy["cipher"] = "auto"
+			}
+			sniKey = "servername"
+		case "vless":
+			proxy["type"] = "vless"
+			proxy["uuid"] = outbound.UUID
+			if outbound.Flow != "" {
+				proxy["flow"] = outbound.Flow
+			}
+			sniKey = "servername"
+		case "trojan":
+			proxy["type"] = "trojan"
+			proxy["password"] = outbound.Password
+		case "hysteria":
+			proxy["type"] = "hysteria"
+			proxy["auth-str"] = outbound.AuthStr
+			proxy["up"] = outbound.UpMbps
+			proxy["down"] = outbound.DownMbps
+			var obfs string
+			if json.Unmarshal(outbound.Obfs, &obfs) == nil && obfs != "" {
+				proxy["obfs"] = obfs
+			}
+		case "hysteria2":
+			proxy["type"] = "hysteria2"
+			proxy["password"] = outbound.Password
+			if outbound.UpMbps > 0 {
+				proxy["up"] = outbound.UpMbps
+			}
+			if outbound.DownMbps > 0 {
+				proxy["down"] = outbound.DownMbps
+			}
+			var obfs struct {
+				Type     string `json:"type"`
+				Password string `json:"password"`
+			}
+			if json.Unmarshal(outbound.Obfs, &obfs) == nil && obfs.Type != "" {
+				proxy["obfs"] = obfs.Type
+				proxy["obfs-password"] = obfs.Password
+			}
+		case "tuic":
+			proxy["type"] = "tuic"
+			proxy["uuid"] = outbound.UUID
+			proxy["password"] = outbound.Password
+			if outbound.CongestionControl != "" {
+				proxy["congestion-controller"] = outbound.CongestionControl
+			}
+			if outbound.UDPRelayMode != "" {
+				proxy["udp-relay-mode"] = outbound.UDPRelayMode
+			}
+		case "socks":
+			proxy["type"] = "socks5"
+			if outbound.Username != "" {
+				proxy["username"] = outbound.Username
+				proxy["password"] = outbound.Password
+			}
+		case "http":
+			proxy["type"] = "http"
+			if outbound.Username != "" {
+				proxy["username"] = outbound.Username
+				proxy["password"] = outbound.Password
+			}
+		case "wireguard":
+			proxy["type"] = "wireguard"
+			proxy["private-key"] = outbound.PrivateKey
+			proxy["public-key"] = outbound.PeerPublicKey
+			if outbound.PreSharedKey != "" {
+				proxy["pre-shared-key"] = outbound.PreSharedKey
+			}
+			if outbound.MTU > 0 {
+				proxy["mtu"] = outbound.MTU
+			}
+			for _, address := range outbound.LocalAddress {
+				ip, _, _ := strings.Cut(address, "/")
+				if strings.Contains(ip, ":") {
+					proxy["ipv6"] = ip
+				} else {
+					proxy["ip"] = ip
+				}
+			}
+		default:
+			continue
+		}
+
+		if tls := outbound.TLS; tls != nil && tls.Enabled {
+			if outbound.Type == "vmess" || outbound.Type == "vless" || outbound.Type == "http" {
+				proxy["tls"] = true
+			}
+			if tls.ServerName != "" {
+				proxy[sniKey] = tls.ServerName
+			}
+			if tls.Insecure {
+				proxy["skip-cert-verify"] = true
+			}
+			if len(tls.ALPN) > 0 {
+				proxy["alpn"] = tls.ALPN
+			}
+			if tls.UTLS.Enabled && tls.UTLS.Fingerprint != "" {
+				proxy["client-fingerprint"] = tls.UTLS.Fingerprint
+			}
+			if tls.Reality.Enabled {
+				proxy["reality-opts"] = map[string]any{
+					"public-key": tls.Reality.PublicKey,
+					"short-id":   tls.Reality.ShortID,
+				}
+			}
+		}
+
+		if transport := outbound.Transport; transport != nil {
+			switch transport.Type {
+			case "ws":
+				proxy["network"] = "ws"
+				wsOpts := map[string]any{"path": transport.Path}
+				if len(transport.Headers) > 0 {
+					wsOpts["headers"] = transport.Headers
+				}
+				proxy["ws-opts"] = wsOpts
+			case "grpc":
+				proxy["network"] = "grpc"
+				proxy["grpc-opts"] = map[string]any{"grpc-service-name": transport.ServiceName}
+			default:
+				log.Warnln("skip sing-box outbound %s: unsupported transport %s", outbound.Tag, transport.Type)
+				continue
+			}
+		}
+
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// singBoxPlugin 将 sing-box 的 plugin 和 plugin_opts（如 obfs=http;obfs-host=example.com）转换为 Clash 的 plugin 和 plugin-opts
+func singBoxPlugin(plugin string, pluginOpts string) (string, map[string]any, error) {
+	values := make(map[string]string)
+	for _, item := range strings.Split(pluginOpts, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		values[key] = value
+	}
+	switch plugin {
+	case "obfs-local":
+		return "obfs", map[string]any{"mode": values["obfs"], "host": values["obfs-host"]}, nil
+	case "v2ray-plugin":
+		_, tls := values["tls"]
+		mode := values["mode"]
+		if mode == "" {
+			mode = "websocket"
+		}
+		return "v2ray-plugin", map[string]any{"mode": mode, "host": values["host"], "path": values["path"], "tls": tls}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported ss plugin %s", plugin)
+}
+
+func configInt(config map[string]any, key string) int {
+	// hysteria 的 up/down 可能写成 "100 Mbps"
+	fields := strings.Fields(configString(config, key))
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fields[0])
+	return n
+}
+
+func configStrings(config map[string]any, key string) []string {
+	switch values := config[key].(type) {
+	case []string:
+		return values
+	case []any:
+		result := make([]string, 0, len(values))
+		for _, v := range values {
+			result = append(result, fmt.Sprint(v))
+		}
+		return result
+	}
+	return nil
+}
+
+// clashToSingBox 将 Clash 节点配置转换为 sing-box outbound，tag 会替换原有节点名
+func clashToSingBox(config map[string]any, tag string) (map[string]any, error) {
+	outbound := map[string]any{
+		"tag":         tag,
+		"server":      configString(config, "server"),
+		"server_port": configInt(config, "port"),
+	}
+	tlsEnabled := configBool(config, "tls")
+	sniKey := "sni"
+
+	switch proxyType := configString(config, "type"); proxyType {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = configString(config, "cipher")
+		outbound["password"] = configString(config, "password")
+		if plugin := configString(config, "plugin"); plugin != "" {
+			opts := configSubMap(config, "plugin-opts")
+			switch plugin {
+			case "obfs":
+				outbound["plugin"] = "obfs-local"
+				outbound["plugin_opts"] = fmt.Sprintf("obfs=%s;obfs-host=%s", configString(opts, "mode"), configString(opts, "host"))
+			case "v2ray-plugin":
+				pluginOpts := fmt.Sprintf("mode=%s;host=%s;path=%s", configString(opts, "mode"), configString(opts, "host"), configString(opts, "path"))
+				if configBool(opts, "tls") {
+					pluginOpts += ";tls"
+				}
+				outbound["plugin"] = "v2ray-plugin"
+				outbound["plugin_opts"] = pluginOpts
+			default:
+				return nil, fmt.Errorf("unsupported ss plugin %s", plugin)
+			}
+		}
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = configString(config, "uuid")
+		outbound["alter_id"] = configInt(config, "alterId")
+		outbound["security"] = configString(config, "cipher")
+		sniKey = "servername"
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = configString(config, "uuid")
+		if flow := configString(config, "flow"); flow != "" {
+			outbound["flow"] = flow
+		}
+		sniKey = "servername"
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = configString(config, "password")
+		tlsEnabled = true
+	case "hysteria":
+		outbound["type"] = "hysteria"
+		outbound["auth_str"] = configString(config, "auth-str")
+		outbound["up_mbps"] = configInt(config, "up")
+		outbound["down_mbps"] = configInt(config, "down")
+		if obfs := configString(config, "obfs"); obfs != "" {
+			outbound["obfs"] = obfs
+		}
+		tlsEnabled = true
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = configString(config, "password")
+		if up := configInt(config, "up"); up > 0 {
+			outbound["up_mbps"] = up
+		}
+		if down := configInt(config, "down"); down > 0 {
+			outbound["down_mbps"] = down
+		}
+		if obfs := configString(config, "obfs"); obfs != "" {
+			outbound["obfs"] = map[string]any{"type": obfs, "password": configString(config, "obfs-password")}
+		}
+		tlsEnabled = true
+	case "tuic":
+		outbound["type"] = "tuic"
+		outbound["uuid"] = configString(config, "uuid")
+		outbound["password"] = configString(config, "password")
+		if cc := configString(config, "congestion-controller"); cc != "" {
+			outbound["congestion_control"] = cc
+		}
+		if mode := configString(config, "udp-relay-mode"); mode != "" {
+			outbound["udp_relay_mode"] = mode
+		}
+		tlsEnabled = true
+	case "socks5", "http":
+		outbound["type"] = "http"
+		if proxyType == "socks5" {
+			outbound["type"] = "socks"
+		}
+		if username := configString(config, "username"); username != "" {
+			outbound["username"] = username
+			outbound["password"] = configString(config, "password")
+		}
+	case "wireguard":
+		outbound["type"] = "wireguard"
+		outbound["private_key"] = configString(config, "private-key")
+		outbound["peer_public_key"] = configString(config, "public-key")
+		if psk := configString(config, "pre-shared-key"); psk != "" {
+			outbound["pre_shared_key"] = psk
+		}
+		if mtu := configInt(config, "mtu"); mtu > 0 {
+			outbound["mtu"] = mtu
+		}
+		var localAddress []string
+		if ip := configString(config, "ip"); ip != "" {
+			localAddress = append(localAddress, ip+"/32")
+		}
+		if ip := configString(config, "ipv6"); ip != "" {
+			localAddress = append(localAddress, ip+"/128")
+		}
+		outbound["local_address"] = localAddress
+	default:
+		return nil, fmt.Errorf("unsupported proxy type %s", proxyType)
+	}
+
+	if tlsEnabled {
+		tls := map[string]any{"enabled": true}
+		if sni := configString(config, sniKey); sni != "" {
+			tls["server_name"] = sni
+		}
+		if configBool(config, "skip-cert-verify") {
+			tls["insecure"] = true
+		}
+		if alpn := configStrings(config, "alpn"); len(alpn) > 0 {
+			tls["alpn"] = alpn
+		}
+		if fp := configString(config, "client-fingerprint"); fp != "" {
+			tls["utls"] = map[string]any{"enabled": true, "fingerprint": fp}
+		}
+		if reality, ok := config["reality-opts"].(map[string]any); ok {
+			tls["reality"] = map[string]any{
+				"enabled":    true,
+				"public_key": configString(reality, "public-key"),
+				"short_id":   configString(reality, "short-id"),
+			}
+		}
+		outbound["tls"] = tls
+	}
+
+	switch configString(config, "network") {
+	case "ws":
+		wsOpts := configSubMap(config, "ws-opts")
+		transport := map[string]any{"type": "ws", "path": configString(wsOpts, "path")}
+		if headers := configSubMap(wsOpts, "headers"); len(headers) > 0 {
+			transport["headers"] = headers
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = map[string]any{
+			"type":         "grpc",
+			"service_name": configString(configSubMap(config, "grpc-opts"), "grpc-service-name"),
+		}
+	}
+	return outbound, nil
+}
+
+// writeSingBoxConfig 输出 sing-box 配置，包含全部节点以及 select / urltest 分组，没有节点时不输出分组
+func writeSingBoxConfig(filePath string, results []Result, proxies map[string]CProxy) error {
+	var tags []string
+	var nodes []any
+	for _, result := range results {
+		proxy, ok := proxies[result.Name]
+		if !ok {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		tag := outputName(config, result, len(tags)+1)
+		outbound, err := clashToSingBox(config, tag)
+		if err != nil {
+			log.Warnln("skip %s: %s", result.Name, err)
+			continue
+		}
+		tags = append(tags, tag)
+		nodes = append(nodes, outbound)
+	}
+
+	var outbounds []any
+	// sing-box 不接受空的分组，没有节点通过时只输出 direct
+	if len(tags) > 0 {
+		outbounds = append(outbounds,
+			map[string]any{"type": "selector", "tag": "proxy", "outbounds": append([]string{"auto"}, tags...), "default": "auto"},
+			map[string]any{"type": "urltest", "tag": "auto", "outbounds": tags},
+		)
+	}
+	outbounds = append(outbounds, nodes...)
+	outbounds = append(outbounds, map[string]any{"type": "direct", "tag": "direct"})
+
+	buf, err := json.MarshalIndent(map[string]any{"outbounds": outbounds}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, buf, 0o644)
+}
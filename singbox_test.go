@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSingBoxOutbounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		outbound string
+		want     []map[string]any
+	}{
+		{
+			name:     "shadowsocks obfs",
+			outbound: `{"type":"shadowsocks","tag":"ss","server":"1.2.3.4","server_port":8388,"method":"aes-128-gcm","password":"pass","plugin":"obfs-local","plugin_opts":"obfs=http;obfs-host=example.com"}`,
+			want: []map[string]any{{
+				"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388, "cipher": "aes-128-gcm", "password": "pass",
+				"plugin": "obfs", "plugin-opts": map[string]any{"mode": "http", "host": "example.com"},
+			}},
+		},
+		{
+			name:     "shadowsocks v2ray-plugin",
+			outbound: `{"type":"shadowsocks","tag":"ss","server":"1.2.3.4","server_port":443,"method":"none","password":"pass","plugin":"v2ray-plugin","plugin_opts":"mode=websocket;host=example.com;path=/ws;tls"}`,
+			want: []map[string]any{{
+				"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 443, "cipher": "none", "password": "pass",
+				"plugin": "v2ray-plugin", "plugin-opts": map[string]any{"mode": "websocket", "host": "example.com", "path": "/ws", "tls": true},
+			}},
+		},
+		{
+			name:     "shadowsocks unsupported plugin",
+			outbound: `{"type":"shadowsocks","tag":"ss","server":"1.2.3.4","server_port":8388,"method":"aes-128-gcm","password":"pass","plugin":"kcptun"}`,
+			want:     []map[string]any{},
+		},
+		{
+			name:     "vless reality",
+			outbound: `{"type":"vless","tag":"vless","server":"example.com","server_port":443,"uuid":"uuid","flow":"xtls-rprx-vision","tls":{"enabled":true,"server_name":"sni.example.com","utls":{"enabled":true,"fingerprint":"chrome"},"reality":{"enabled":true,"public_key":"key","short_id":"id"}}}`,
+			want: []map[string]any{{
+				"name": "vless", "type": "vless", "server": "example.com", "port": 443, "uuid": "uuid", "flow": "xtls-rprx-vision",
+				"tls": true, "servername": "sni.example.com", "client-fingerprint": "chrome",
+				"reality-opts": map[string]any{"public-key": "key", "short-id": "id"},
+			}},
+		},
+		{
+			name:     "hysteria2 obfs",
+			outbound: `{"type":"hysteria2","tag":"hy2","server":"1.2.3.4","server_port":443,"password":"pass","obfs":{"type":"salamander","password":"obfs"}}`,
+			want: []map[string]any{{
+				"name": "hy2", "type": "hysteria2", "server": "1.2.3.4", "port": 443, "password": "pass",
+				"obfs": "salamander", "obfs-password": "obfs",
+			}},
+		},
+		{
+			name:     "unsupported transport",
+			outbound: `{"type":"vmess","tag":"vmess","server":"1.2.3.4","server_port":443,"uuid":"uuid","transport":{"type":"quic"}}`,
+			want:     []map[string]any{},
+		},
+		{
+			name:     "selector",
+			outbound: `{"type":"selector","tag":"proxy","outbounds":["ss"]}`,
+			want:     []map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSingBoxOutbounds([]byte(`{"outbounds":[` + tt.outbound + `]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSingBoxOutbounds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 转换为 sing-box outbound 后再解析回来，应得到相同的节点
+func TestSingBoxRoundTrip(t *testing.T) {
+	tests := []map[string]any{
+		{
+			"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388, "cipher": "aes-128-gcm", "password": "pass",
+			"plugin": "obfs", "plugin-opts": map[string]any{"mode": "tls", "host": "example.com"},
+		},
+		{
+			"name": "ss-v2ray", "type": "ss", "server": "1.2.3.4", "port": 443, "cipher": "none", "password": "pass",
+			"plugin": "v2ray-plugin", "plugin-opts": map[string]any{"mode": "websocket", "host": "example.com", "path": "/ws", "tls": false},
+		},
+		{
+			"name": "trojan", "type": "trojan", "server": "example.com", "port": 443, "password": "pass",
+			"sni": "sni.example.com", "skip-cert-verify": true, "alpn": []string{"h2", "http/1.1"},
+		},
+		{
+			"name": "socks5", "type": "socks5", "server": "1.2.3.4", "port": 1080, "username": "user", "password": "pass",
+		},
+	}
+	for _, config := range tests {
+		name := configString(config, "name")
+		t.Run(name, func(t *testing.T) {
+			outbound, err := clashToSingBox(config, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf, err := json.Marshal(map[string]any{"outbounds": []any{outbound}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxies, err := parseSingBoxOutbounds(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(proxies) != 1 || !reflect.DeepEqual(proxies[0], config) {
+				t.Errorf("round trip of %s = %v, want %v", buf, proxies, config)
+			}
+		})
+	}
+}
+
+func TestWriteSingBoxConfig(t *testing.T) {
+	proxies := map[string]CProxy{
+		"a": {SecretConfig: map[string]any{"name": "a", "type": "socks5", "server": "1.2.3.4", "port": 1080}},
+	}
+	tests := []struct {
+		name    string
+		results []Result
+		want    []string
+	}{
+		{name: "empty", want: []string{"direct"}},
+		{name: "one", results: []Result{{Name: "a", Bandwidth: 1024}}, want: []string{"selector", "urltest", "socks", "direct"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "singbox.json")
+			if err := writeSingBoxConfig(path, tt.results, proxies); err != nil {
+				t.Fatal(err)
+			}
+			buf, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var config struct {
+				Outbounds []struct {
+					Type      string   `json:"type"`
+					Tag       string   `json:"tag"`
+					Outbounds []string `json:"outbounds"`
+				} `json:"outbounds"`
+			}
+			if err := json.Unmarshal(buf, &config); err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			for _, outbound := range config.Outbounds {
+				types = append(types, outbound.Type)
+				if (outbound.Type == "selector" || outbound.Type == "urltest") && len(outbound.Outbounds) == 0 {
+					t.Errorf("group %s has no outbounds", outbound.Tag)
+				}
+			}
+			if !reflect.DeepEqual(types, tt.want) {
+				t.Errorf("outbound types = %v, want %v", types, tt.want)
+			}
+		})
+	}
+}
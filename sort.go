@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type sortKey struct {
+	Field string
+	Desc  bool
+}
+
+// sortFields 为支持的排序字段及其默认方向，key 为字段名和缩写
+var sortFields = map[string]struct {
+	field string
+	label string
+	desc  bool
+}{
+	"b":         {"bandwidth", "带宽", true},
+	"bandwidth": {"bandwidth", "带宽", true},
+	"t":         {"ttfb", "延迟", false},
+	"ttfb":      {"ttfb", "延迟", false},
+	"score":     {"score", "综合评分", true},
+	"latency":   {"latency", "URL延迟", false},
+	"country":   {"country", "地区", false},
+	"name":      {"name", "名称", false},
+	"type":      {"type", "类型", false},
+}
+
+// sortKeys 为 -sort 解析后的排序字段，为空时不排序
+var sortKeys []sortKey
+
+// parseSortKeys 解析 country,bandwidth:desc 格式的多字段排序，未指定方向时使用 order，order 为空时使用字段的默认方向
+func parseSortKeys(spec string, order string) ([]sortKey, error) {
+	switch order {
+	case "", "asc", "desc":
+	default:
+		return nil, fmt.Errorf("unsupported sort order: %s", order)
+	}
+	var keys []sortKey
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, keyOrder, hasOrder := strings.Cut(item, ":")
+		field, ok := sortFields[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported sort field: %s", name)
+		}
+		key := sortKey{Field: field.field, Desc: field.desc}
+		if !hasOrder {
+			keyOrder = order
+		}
+		if keyOrder != "" {
+			switch strings.ToLower(keyOrder) {
+			case "asc":
+				key.Desc = false
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, fmt.Errorf("unsupported sort order: %s", keyOrder)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func sortedByScore() bool {
+	for _, key := range sortKeys {
+		if key.Field == "score" {
+			return true
+		}
+	}
+	return false
+}
+
+// sortLabel 返回排序字段的中文描述，如 地区、带宽
+func sortLabel(keys []sortKey) string {
+	labels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, sortFields[key.Field].label)
+	}
+	return strings.Join(labels, "、")
+}
+
+// compareResults 按单个字段比较两个结果，返回负数表示 a 排在 b 之前
+func compareResults(a, b *Result, key sortKey, proxies map[string]CProxy) int {
+	var c int
+	switch key.Field {
+	case "bandwidth":
+		c = compareFloat(a.Bandwidth, b.Bandwidth)
+	case "ttfb", "latency":
+		x, y := a.TTFB, b.TTFB
+		if key.Field == "latency" {
+			x, y = a.Latency, b.Latency
+		}
+		// 失败的节点延迟为 -1，无论升序降序都排在最后
+		if (x > 0) != (y > 0) {
+			if x > 0 {
+				return -1
+			}
+			return 1
+		}
+		c = compareFloat(float64(x), float64(y))
+	case "score":
+		c = compareFloat(a.Score, b.Score)
+	case "country":
+		c = strings.Compare(nodeCountry(*a), nodeCountry(*b))
+	case "name":
+		c = strings.Compare(a.Name, b.Name)
+	case "type":
+		c = strings.Compare(proxyType(proxies, a.Name), proxyType(proxies, b.Name))
+	}
+	if key.Desc {
+		return -c
+	}
+	return c
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func proxyType(proxies map[string]CProxy, name string) string {
+	if proxy, ok := proxies[name]; ok {
+		return proxy.Type().String()
+	}
+	return ""
+}
+
+// sortResults 按多个字段依次排序，前面的字段相同时再比较后面的字段，输出的各类文件都沿用这个顺序
+func sortResults(results []Result, keys []sortKey, proxies map[string]CProxy) {
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, key := range keys {
+			if c := compareResults(&results[i], &results[j], key, proxies); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
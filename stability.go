@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// StabilityResult 为长时间稳定性测试中一个节点的全部探测结果
+type StabilityResult struct {
+	Name       string
+	Rounds     int
+	TTFBs      []float64 // 成功探测的延迟（毫秒）
+	Bandwidths []float64 // 成功探测的带宽（字节/秒）
+}
+
+// Uptime 返回探测成功的比例
+func (r *StabilityResult) Uptime() float64 {
+	if r.Rounds == 0 {
+		return 0
+	}
+	return float64(len(r.TTFBs)) / float64(r.Rounds)
+}
+
+// meanStddev 返回平均值和标准差
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// runStabilityTest 在 duration 内每隔 interval 测试一轮全部节点，最后按可用率和平均带宽排序输出
+func runStabilityTest(names []string, proxies map[string]CProxy, livenessURL string, downloadSize int, timeout, duration, interval time.Duration) {
+	results := make([]*StabilityResult, len(names))
+	for i, name := range names {
+		results[i] = &StabilityResult{Name: name}
+	}
+
+	deadline := time.Now().Add(duration)
+	for round := 1; ; round++ {
+		start := time.Now()
+		passed := 0
+		for _, result := range results {
+			// 每一轮单独计算 -max-node-data 的额度
+			r := TestProxyConcurrent(result.Name, capNodeData(proxies[result.Name]), livenessURL, downloadSize, timeout, *concurrent)
+			result.Rounds++
+			if r.Bandwidth > 0 {
+				passed++
+				result.TTFBs = append(result.TTFBs, float64(r.TTFB)/float64(time.Millisecond))
+				result.Bandwidths = append(result.Bandwidths, r.Bandwidth)
+			}
+		}
+		fmt.Printf("[%s] 第 %d 轮：%d/%d 个节点可用\n", time.Now().Format("15:04:05"), round, passed, len(results))
+
+		next := start.Add(interval)
+		if next.After(deadline) {
+			break
+		}
+		time.Sleep(time.Until(next))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Uptime() != results[j].Uptime() {
+			return results[i].Uptime() > results[j].Uptime()
+		}
+		bi, _ := meanStddev(results[i].Bandwidths)
+		bj, _ := meanStddev(results[j].Bandwidths)
+		return bi > bj
+	})
+	printStabilityResults(results)
+}
+
+func printStabilityResults(results []*StabilityResult) {
+	fmt.Println("\n\n===稳定性测试结果===")
+	fmt.Printf("%-42s\t%-8s\t%-12s\t%-12s\t%-12s\t%-12s\n", "节点", "可用率", "平均延迟", "延迟标准差", "平均带宽", "带宽标准差")
+	for _, result := range results {
+		ttfb, ttfbStddev := meanStddev(result.TTFBs)
+		bandwidth, bandwidthStddev := meanStddev(result.Bandwidths)
+		color := ""
+		if result.Uptime() < 0.9 {
+			color = red
+		} else if result.Uptime() >= 0.99 {
+			color = green
+		}
+		latency, jitter := "N/A", "N/A"
+		if len(result.TTFBs) > 0 {
+			latency, jitter = fmt.Sprintf("%.02fms", ttfb), fmt.Sprintf("%.02fms", ttfbStddev)
+		}
+		fmt.Printf("%s%-42s\t%-8s\t%-12s\t%-12s\t%-12s\t%-12s\033[0m\n", color, formatName(result.Name),
+			fmt.Sprintf("%.1f%%", result.Uptime()*100), latency, jitter,
+			formatBandwidth(bandwidth), formatBandwidth(bandwidthStddev))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type statusRange struct {
+	min, max int
+}
+
+// acceptedStatuses 为 -accept-status 解析出的状态码范围，下载测试的响应状态码在其中才算成功
+var acceptedStatuses []statusRange
+
+// parseStatusRanges 解析逗号分隔的状态码或范围，如 200-299,302
+func parseStatusRanges(s string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(item, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %s", item)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || max < min {
+				return nil, fmt.Errorf("invalid status range %s", item)
+			}
+		}
+		ranges = append(ranges, statusRange{min: min, max: max})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no status specified")
+	}
+	return ranges, nil
+}
+
+func acceptStatus(code int) bool {
+	for _, r := range acceptedStatuses {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
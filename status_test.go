@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []statusRange
+		wantErr bool
+	}{
+		{in: "200", want: []statusRange{{200, 200}}},
+		{in: "200-299,302", want: []statusRange{{200, 299}, {302, 302}}},
+		{in: " 200 - 206 , ,404 ", want: []statusRange{{200, 206}, {404, 404}}},
+		{in: "299-200", wantErr: true},
+		{in: "2xx", wantErr: true},
+		{in: "200-", wantErr: true},
+		{in: ",", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseStatusRanges(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStatusRanges(%q) = %v, %v, want %v, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
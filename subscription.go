@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubscriptionInfo 是订阅返回的 subscription-userinfo 响应头，流量单位为字节
+type SubscriptionInfo struct {
+	Upload   int64
+	Download int64
+	Total    int64
+	Expire   time.Time
+}
+
+// subscriptionInfos 记录每个订阅地址的流量信息
+var subscriptionInfos = make(map[string]*SubscriptionInfo)
+
+// parseSubscriptionUserinfo 解析 upload=1; download=2; total=3; expire=4 格式的响应头
+func parseSubscriptionUserinfo(header string) (*SubscriptionInfo, error) {
+	info := &SubscriptionInfo{}
+	for _, field := range strings.Split(header, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			// 部分面板会返回浮点数
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if ferr != nil {
+				return nil, fmt.Errorf("invalid %s: %s", key, value)
+			}
+			n = int64(f)
+		}
+		switch strings.ToLower(key) {
+		case "upload":
+			info.Upload = n
+		case "download":
+			info.Download = n
+		case "total":
+			info.Total = n
+		case "expire":
+			if n > 0 {
+				info.Expire = time.Unix(n, 0)
+			}
+		}
+	}
+	return info, nil
+}
+
+func recordSubscriptionInfo(configURL string, header string) {
+	if header == "" {
+		return
+	}
+	info, err := parseSubscriptionUserinfo(header)
+	if err != nil {
+		log.Warnln("failed to parse subscription-userinfo of %s: %s", configURL, err)
+		return
+	}
+	subscriptionInfos[configURL] = info
+}
+
+// Remaining 返回剩余流量，total 为 0 表示不限流量，返回 -1
+func (s *SubscriptionInfo) Remaining() int64 {
+	if s.Total <= 0 {
+		return -1
+	}
+	return s.Total - s.Upload - s.Download
+}
+
+func (s *SubscriptionInfo) String() string {
+	traffic := "不限流量"
+	if remaining := s.Remaining(); remaining >= 0 {
+		traffic = fmt.Sprintf("剩余流量 %s / %s", formatBytes(float64(remaining)), formatBytes(float64(s.Total)))
+	}
+	expire := "长期有效"
+	if !s.Expire.IsZero() {
+		expire = "到期时间 " + s.Expire.Format("2006-01-02")
+		if time.Now().After(s.Expire) {
+			expire += " (已过期)"
+		}
+	}
+	return traffic + ", " + expire
+}
+
+func formatBytes(v float64) string {
+	switch {
+	case v < 1024:
+		return fmt.Sprintf("%.0fB", v)
+	case v < 1024*1024:
+		return fmt.Sprintf("%.02fKB", v/1024)
+	case v < 1024*1024*1024:
+		return fmt.Sprintf("%.02fMB", v/1024/1024)
+	default:
+		return fmt.Sprintf("%.02fGB", v/1024/1024/1024)
+	}
+}
+
+// checkSubscriptionTraffic 按订阅估算本次测试消耗的流量，剩余流量不足时返回错误
+func checkSubscriptionTraffic(names []string, sources map[string]string, downloadSize int64) error {
+	counts := make(map[string]int64)
+	for _, name := range names {
+		counts[sources[name]]++
+	}
+	for source, count := range counts {
+		info, ok := subscriptionInfos[source]
+		if !ok {
+			continue
+		}
+		remaining := info.Remaining()
+		if estimated := count * downloadSize; remaining >= 0 && remaining < estimated {
+			return fmt.Errorf("remaining traffic %s of %s is less than the estimated consumption %s",
+				formatBytes(float64(remaining)), source, formatBytes(float64(estimated)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type summaryGroup struct {
+	Name    string
+	Total   int
+	Passed  int
+	Results []Result
+}
+
+// median 返回中位数，values 会被排序
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// groupResults 按 key 对测试结果分组，跳过的节点不参与统计，分组按节点数从多到少排序
+func groupResults(results []Result, key func(r *Result) string) []*summaryGroup {
+	groups := make(map[string]*summaryGroup)
+	for i := range results {
+		result := &results[i]
+		if result.Skipped {
+			continue
+		}
+		name := key(result)
+		if name == "" {
+			name = "未知"
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = &summaryGroup{Name: name}
+			groups[name] = group
+		}
+		group.Total++
+		group.Results = append(group.Results, *result)
+	}
+
+	sorted := make([]*summaryGroup, 0, len(groups))
+	for _, group := range groups {
+		group.Results = passedResults(group.Results)
+		group.Passed = len(group.Results)
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// printSummary 按地区和协议类型输出节点数、可用率、带宽和延迟的中位数，有多个配置来源时还按来源统计
+func printSummary(results []Result, proxies map[string]CProxy) {
+	fmt.Println("\n\n===按地区统计===")
+	printSummaryGroups("地区", groupResults(results, func(r *Result) string { return nodeCountry(*r) }))
+	fmt.Println("\n===按协议统计===")
+	printSummaryGroups("协议", groupResults(results, func(r *Result) string { return proxyType(proxies, r.Name) }))
+	if sources := groupResults(results, func(r *Result) string { return sourceLabel(r.Source) }); len(sources) > 1 {
+		fmt.Println("\n===按来源统计===")
+		printSummaryGroups("来源", sources)
+	}
+	printSharedExits(results)
+}
+
+// printSharedExits 列出出口 IP 相同的节点，反映订阅中实际不同出口的数量，未检测出口 IP 时不输出
+func printSharedExits(results []Result) {
+	exits := make(map[string][]string)
+	labels := make(map[string]string)
+	nodes := 0
+	for _, result := range results {
+		if result.ExitIP == "" {
+			continue
+		}
+		nodes++
+		exits[result.ExitIP] = append(exits[result.ExitIP], result.Name)
+		labels[result.ExitIP] = strings.TrimSpace(result.ExitIP + " " + result.ASN)
+	}
+	if len(exits) == 0 {
+		return
+	}
+
+	shared := make([]string, 0, len(exits))
+	for ip, names := range exits {
+		if len(names) > 1 {
+			shared = append(shared, ip)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if len(exits[shared[i]]) != len(exits[shared[j]]) {
+			return len(exits[shared[i]]) > len(exits[shared[j]])
+		}
+		return shared[i] < shared[j]
+	})
+
+	fmt.Println("\n===相同出口===")
+	fmt.Printf("%d 个节点共有 %d 个不同的出口 IP\n", nodes, len(exits))
+	for _, ip := range shared {
+		names := exits[ip]
+		sort.Strings(names)
+		fmt.Printf("%s（%d 个节点）\t%s\n", labels[ip], len(names), strings.Join(names, ", "))
+	}
+}
+
+func printSummaryGroups(header string, groups []*summaryGroup) {
+	fmt.Printf("%-16s\t%-8s\t%-8s\t%-12s\t%-12s\n", header, "节点数", "可用率", "带宽中位数", "延迟中位数")
+	for _, group := range groups {
+		bandwidths := make([]float64, 0, len(group.Results))
+		ttfbs := make([]float64, 0, len(group.Results))
+		for _, result := range group.Results {
+			bandwidths = append(bandwidths, result.Bandwidth)
+			ttfbs = append(ttfbs, float64(result.TTFB))
+		}
+		fmt.Printf("%-16s\t%-8d\t%-8s\t%-12s\t%-12s\n", group.Name, group.Total,
+			fmt.Sprintf("%.1f%%", float64(group.Passed)*100/float64(group.Total)),
+			formatBandwidth(median(bandwidths)), formatMilliseconds(time.Duration(median(ttfbs))))
+	}
+}
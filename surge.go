@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var qxServerRegex = regexp.MustCompile(`(?im)^\s*(shadowsocks|vmess|vless|trojan|http|socks5)\s*=.*\btag\s*=`)
+
+// isSurgeConfig 判断内容是否为包含 [Proxy] 段的 Surge 配置
+func isSurgeConfig(buf []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		if strings.EqualFold(strings.TrimSpace(scanner.Text()), "[Proxy]") {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuantumultXList 判断内容是否为 Quantumult X 的 server_local 节点列表
+func isQuantumultXList(buf []byte) bool {
+	return qxServerRegex.Match(buf)
+}
+
+// splitParams 拆分逗号分隔的参数，key=value 形式的参数存入 map，其余按顺序返回
+func splitParams(s string) ([]string, map[string]string) {
+	var positional []string
+	params := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if key, value, ok := strings.Cut(item, "="); ok {
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		} else if item != "" {
+			positional = append(positional, item)
+		}
+	}
+	return positional, params
+}
+
+// parseSurgeProxies 转换 Surge 配置 [Proxy] 段中的节点，内置策略和不支持的类型会被跳过
+func parseSurgeProxies(buf []byte) ([]map[string]any, error) {
+	var proxies []map[string]any
+	inProxySection := false
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProxySection = strings.EqualFold(line, "[Proxy]")
+			continue
+		}
+		if !inProxySection || line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		name, definition, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		proxy, err := convertSurgeProxy(name, definition)
+		if err != nil {
+			log.Warnln("skip surge proxy %s: %s", name, err)
+			continue
+		}
+		if proxy == nil {
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no supported proxy found in surge config")
+	}
+	return proxies, nil
+}
+
+func convertSurgeProxy(name string, definition string) (map[string]any, error) {
+	positional, params := splitParams(definition)
+	if len(positional) < 1 {
+		return nil, fmt.Errorf("missing proxy type")
+	}
+	proxyType := strings.ToLower(positional[0])
+	switch proxyType {
+	case "direct", "reject", "reject-tinygif":
+		return nil, nil
+	}
+	if len(positional) < 3 {
+		return nil, fmt.Errorf("missing server or port")
+	}
+	port, err := strconv.Atoi(positional[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", positional[2])
+	}
+
+	proxy := map[string]any{
+		"name":   name,
+		"server": positional[1],
+		"port":   port,
+	}
+	if params["udp-relay"] == "true" {
+		proxy["udp"] = true
+	}
+	if params["skip-cert-verify"] == "true" || params["skip-cert-verify"] == "1" {
+		proxy["skip-cert-verify"] = true
+	}
+
+	switch proxyType {
+	case "ss", "custom":
+		proxy["type"] = "ss"
+		proxy["cipher"] = params["encrypt-method"]
+		proxy["password"] = params["password"]
+		// 旧版的 custom 类型按位置给出加密方式和密码：custom, server, port, method, password, module
+		if proxyType == "custom" && len(positional) >= 5 {
+			proxy["cipher"], proxy["password"] = positional[3], positional[4]
+		}
+		if obfs := params["obfs"]; obfs != "" {
+			proxy["plugin"] = "obfs"
+			proxy["plugin-opts"] = map[string]any{"mode": obfs, "host": params["obfs-host"]}
+		}
+	case "vmess":
+		proxy["type"] = "vmess"
+		proxy["uuid"] = params["username"]
+		proxy["alterId"] = 0
+		proxy["cipher"] = "auto"
+		if params["tls"] == "true" {
+			proxy["tls"] = true
+		}
+		if sni := params["sni"]; sni != "" {
+			proxy["servername"] = sni
+		}
+		applySurgeWebSocket(proxy, params)
+	case "trojan":
+		proxy["type"] = "trojan"
+		proxy["password"] = params["password"]
+		if sni := params["sni"]; sni != "" {
+			proxy["sni"] = sni
+		}
+		applySurgeWebSocket(proxy, params)
+	case "http", "https", "socks5", "socks5-tls":
+		proxy["type"] = "http"
+		if strings.HasPrefix(proxyType, "socks5") {
+			proxy["type"] = "socks5"
+		}
+		if proxyType == "https" || proxyType == "socks5-tls" {
+			proxy["tls"] = true
+		}
+		username, password := params["username"], params["password"]
+		if len(positional) >= 5 {
+			username, password = positional[3], positional[4]
+		}
+		if username != "" {
+			proxy["username"] = username
+			proxy["password"] = password
+		}
+	case "snell":
+		proxy["type"] = "snell"
+		proxy["psk"] = params["psk"]
+		if version, err := strconv.Atoi(params["version"]); err == nil {
+			proxy["version"] = version
+		}
+		if obfs := params["obfs"]; obfs != "" {
+			proxy["obfs-opts"] = map[string]any{"mode": obfs, "host": params["obfs-host"]}
+		}
+	case "hysteria2":
+		proxy["type"] = "hysteria2"
+		proxy["password"] = params["password"]
+		if sni := params["sni"]; sni != "" {
+			proxy["sni"] = sni
+		}
+		if down, err := strconv.Atoi(params["download-bandwidth"]); err == nil && down > 0 {
+			proxy["down"] = down
+		}
+	case "tuic":
+		proxy["type"] = "tuic"
+		proxy["token"] = params["token"]
+		if sni := params["sni"]; sni != "" {
+			proxy["sni"] = sni
+		}
+		if alpn := params["alpn"]; alpn != "" {
+			proxy["alpn"] = []string{alpn}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", proxyType)
+	}
+	return proxy, nil
+}
+
+func applySurgeWebSocket(proxy map[string]any, params map[string]string) {
+	if params["ws"] != "true" {
+		return
+	}
+	proxy["network"] = "ws"
+	wsOpts := map[string]any{"path": params["ws-path"]}
+	if raw := params["ws-headers"]; raw != "" {
+		headers := make(map[string]string)
+		for _, header := range strings.Split(raw, "|") {
+			if key, value, ok := strings.Cut(header, ":"); ok {
+				headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		wsOpts["headers"] = headers
+	}
+	proxy["ws-opts"] = wsOpts
+}
+
+// parseQuantumultXServers 转换 Quantumult X 的节点行，如 shadowsocks=host:port, method=..., password=..., tag=name
+func parseQuantumultXServers(buf []byte) ([]map[string]any, error) {
+	var proxies []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		proxyType, definition, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		proxyType = strings.ToLower(strings.TrimSpace(proxyType))
+		switch proxyType {
+		case "shadowsocks", "vmess", "vless", "trojan", "http", "socks5":
+		default:
+			continue
+		}
+		proxy, err := convertQuantumultXServer(proxyType, definition)
+		if err != nil {
+			log.Warnln("skip quantumult x server %s: %s", line, err)
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no supported server found in quantumult x list")
+	}
+	return proxies, nil
+}
+
+func convertQuantumultXServer(proxyType string, definition string) (map[string]any, error) {
+	positional, params := splitParams(definition)
+	if len(positional) < 1 {
+		return nil, fmt.Errorf("missing server address")
+	}
+	host, portStr, err := net.SplitHostPort(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", portStr)
+	}
+	if params["tag"] == "" {
+		return nil, fmt.Errorf("missing tag")
+	}
+
+	proxy := map[string]any{
+		"name":   params["tag"],
+		"server": host,
+		"port":   port,
+	}
+	if params["udp-relay"] == "true" {
+		proxy["udp"] = true
+	}
+	if params["tls-verification"] == "false" {
+		proxy["skip-cert-verify"] = true
+	}
+	obfs, obfsHost := params["obfs"], params["obfs-host"]
+	overTLS := params["over-tls"] == "true" || obfs == "wss" || obfs == "over-tls"
+
+	switch proxyType {
+	case "shadowsocks":
+		proxy["type"] = "ss"
+		proxy["cipher"] = params["method"]
+		proxy["password"] = params["password"]
+		switch obfs {
+		case "http", "tls":
+			proxy["plugin"] = "obfs"
+			proxy["plugin-opts"] = map[string]any{"mode": obfs, "host": obfsHost}
+		case "ws", "wss":
+			proxy["plugin"] = "v2ray-plugin"
+			proxy["plugin-opts"] = map[string]any{"mode": "websocket", "host": obfsHost, "path": params["obfs-uri"], "tls": obfs == "wss"}
+		}
+	case "vmess", "vless":
+		proxy["type"] = proxyType
+		proxy["uuid"] = params["password"]
+		if proxyType == "vmess" {
+			proxy["alterId"] = 0
+			proxy["cipher"] = params["method"]
+			if params["method"] == "" {
+				proxy["cipher"] = "auto"
+			}
+		}
+		if overTLS {
+			proxy["tls"] = true
+		}
+		if host := params["tls-host"]; host != "" {
+			proxy["servername"] = host
+		} else if obfsHost != "" && overTLS {
+			proxy["servername"] = obfsHost
+		}
+		if obfs == "ws" || obfs == "wss" {
+			proxy["network"] = "ws"
+			wsOpts := map[string]any{"path": params["obfs-uri"]}
+			if obfsHost != "" {
+				wsOpts["headers"] = map[string]string{"Host": obfsHost}
+			}
+			proxy["ws-opts"] = wsOpts
+		}
+	case "trojan":
+		proxy["type"] = "trojan"
+		proxy["password"] = params["password"]
+		if host := params["tls-host"]; host != "" {
+			proxy["sni"] = host
+		}
+	case "http", "socks5":
+		proxy["type"] = proxyType
+		if overTLS {
+			proxy["tls"] = true
+		}
+		if username := params["username"]; username != "" {
+			proxy["username"] = username
+			proxy["password"] = params["password"]
+		}
+	}
+	return proxy, nil
+}
+
+var surgeNameReplacer = strings.NewReplacer(",", " ", "=", "-")
+
+// clashToSurge 将 Clash 节点配置转换为 Surge [Proxy] 段的一行
+func clashToSurge(config map[string]any, name string) (string, error) {
+	fields := []string{configString(config, "server"), configString(config, "port")}
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, key+"="+value)
+		}
+	}
+	addWebSocket := func() {
+		if configString(config, "network") != "ws" {
+			return
+		}
+		wsOpts := configSubMap(config, "ws-opts")
+		add("ws", "true")
+		add("ws-path", configString(wsOpts, "path"))
+		if host := configString(configSubMap(wsOpts, "headers"), "Host"); host != "" {
+			add("ws-headers", "Host:"+host)
+		}
+	}
+
+	var proxyType string
+	switch configString(config, "type") {
+	case "ss":
+		proxyType = "ss"
+		add("encrypt-method", configString(config, "cipher"))
+		add("password", configString(config, "password"))
+		if plugin := configString(config, "plugin"); plugin != "" {
+			if plugin != "obfs" {
+				return "", fmt.Errorf("unsupported ss plugin %s", plugin)
+			}
+			opts := configSubMap(config, "plugin-opts")
+			add("obfs", configString(opts, "mode"))
+			add("obfs-host", configString(opts, "host"))
+		}
+	case "vmess":
+		proxyType = "vmess"
+		add("username", configString(config, "uuid"))
+		if configInt(config, "alterId") == 0 {
+			add("vmess-aead", "true")
+		}
+		if configBool(config, "tls") {
+			add("tls", "true")
+			add("sni", configString(config, "servername"))
+		}
+		addWebSocket()
+	case "trojan":
+		proxyType = "trojan"
+		add("password", configString(config, "password"))
+		add("sni", configString(config, "sni"))
+		addWebSocket()
+	case "http", "socks5":
+		proxyType = configString(config, "type")
+		if configBool(config, "tls") {
+			proxyType = map[string]string{"http": "https", "socks5": "socks5-tls"}[proxyType]
+		}
+		add("username", configString(config, "username"))
+		add("password", configString(config, "password"))
+	case "snell":
+		proxyType = "snell"
+		add("psk", configString(config, "psk"))
+		add("version", configString(config, "version"))
+		opts := configSubMap(config, "obfs-opts")
+		add("obfs", configString(opts, "mode"))
+		add("obfs-host", configString(opts, "host"))
+	case "hysteria2":
+		proxyType = "hysteria2"
+		add("password", configString(config, "password"))
+		add("sni", configString(config, "sni"))
+		if down := configInt(config, "down"); down > 0 {
+			add("download-bandwidth", strconv.Itoa(down))
+		}
+	case "tuic":
+		token := configString(config, "token")
+		if token == "" {
+			return "", fmt.Errorf("surge only supports tuic v4 with token")
+		}
+		proxyType = "tuic"
+		add("token", token)
+		add("sni", configString(config, "sni"))
+		if alpn := configStrings(config, "alpn"); len(alpn) > 0 {
+			add("alpn", alpn[0])
+		}
+	default:
+		return "", fmt.Errorf("unsupported proxy type %s", configString(config, "type"))
+	}
+	if configBool(config, "skip-cert-verify") {
+		add("skip-cert-verify", "true")
+	}
+	if configBool(config, "udp") {
+		add("udp-relay", "true")
+	}
+	return fmt.Sprintf("%s = %s, %s", surgeNameReplacer.Replace(name), proxyType, strings.Join(fields, ", ")), nil
+}
+
+// clashToQuantumultX 将 Clash 节点配置转换为 Quantumult X server_local 的一行
+func clashToQuantumultX(config map[string]any, name string) (string, error) {
+	var fields []string
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, key+"="+value)
+		}
+	}
+	address := net.JoinHostPort(configString(config, "server"), configString(config, "port"))
+	addTransport := func() {
+		tls := configBool(config, "tls")
+		switch configString(config, "network") {
+		case "ws":
+			wsOpts := configSubMap(config, "ws-opts")
+			if tls {
+				add("obfs", "wss")
+			} else {
+				add("obfs", "ws")
+			}
+			add("obfs-host", configString(configSubMap(wsOpts, "headers"), "Host"))
+			add("obfs-uri", configString(wsOpts, "path"))
+		case "", "tcp":
+			if tls {
+				add("obfs", "over-tls")
+				add("obfs-host", configString(config, "servername"))
+			}
+		}
+	}
+
+	var proxyType string
+	switch configString(config, "type") {
+	case "ss":
+		proxyType = "shadowsocks"
+		add("method", configString(config, "cipher"))
+		add("password", configString(config, "password"))
+		if plugin := configString(config, "plugin"); plugin != "" {
+			if plugin != "obfs" {
+				return "", fmt.Errorf("unsupported ss plugin %s", plugin)
+			}
+			opts := configSubMap(config, "plugin-opts")
+			add("obfs", configString(opts, "mode"))
+			add("obfs-host", configString(opts, "host"))
+		}
+	case "vmess", "vless":
+		if network := configString(config, "network"); network != "" && network != "tcp" && network != "ws" {
+			return "", fmt.Errorf("unsupported network %s", network)
+		}
+		proxyType = configString(config, "type")
+		method := configString(config, "cipher")
+		if proxyType == "vless" {
+			method = "none"
+		} else if method == "" || method == "auto" {
+			method = "chacha20-ietf-poly1305"
+		}
+		add("method", method)
+		add("password", configString(config, "uuid"))
+		addTransport()
+	case "trojan":
+		proxyType = "trojan"
+		add("password", configString(config, "password"))
+		add("over-tls", "true")
+		add("tls-host", configString(config, "sni"))
+	case "http", "socks5":
+		proxyType = configString(config, "type")
+		add("username", configString(config, "username"))
+		add("password", configString(config, "password"))
+		if configBool(config, "tls") {
+			add("over-tls", "true")
+		}
+	default:
+		return "", fmt.Errorf("unsupported proxy type %s", configString(config, "type"))
+	}
+	if configBool(config, "skip-cert-verify") {
+		add("tls-verification", "false")
+	}
+	if configBool(config, "udp") {
+		add("udp-relay", "true")
+	}
+	add("tag", strings.ReplaceAll(name, ",", " "))
+	return fmt.Sprintf("%s=%s, %s", proxyType, address, strings.Join(fields, ", ")), nil
+}
+
+// writeProxyLines 按指定格式逐行输出节点，节点名按 -rename 重命名
+func writeProxyLines(filePath string, header string, results []Result, proxies map[string]CProxy,
+	convert func(config map[string]any, name string) (string, error)) error {
+	var lines []string
+	if header != "" {
+		lines = append(lines, header)
+	}
+	// 序号只计算输出的节点，不包括 header 和转换失败的节点
+	emitted := 0
+	for _, result := range results {
+		proxy, ok := proxies[result.Name]
+		if !ok {
+			continue
+		}
+		config, ok := proxy.SecretConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		line, err := convert(config, outputName(config, result, emitted+1))
+		if err != nil {
+			log.Warnln("skip %s: %s", result.Name, err)
+			continue
+		}
+		emitted++
+		lines = append(lines, line)
+	}
+	return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
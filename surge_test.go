@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestConvertSurgeProxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		want       map[string]any
+		wantErr    bool
+	}{
+		{
+			name:       "ss",
+			definition: "ss, 1.2.3.4, 8388, encrypt-method=aes-128-gcm, password=pass, obfs=http, obfs-host=example.com, udp-relay=true",
+			want: map[string]any{
+				"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388, "udp": true,
+				"cipher": "aes-128-gcm", "password": "pass",
+				"plugin": "obfs", "plugin-opts": map[string]any{"mode": "http", "host": "example.com"},
+			},
+		},
+		{
+			name:       "custom",
+			definition: "custom, 1.2.3.4, 8388, chacha20-ietf-poly1305, pass, https://example.com/SSEncrypt.module, obfs=tls, obfs-host=example.com",
+			want: map[string]any{
+				"name": "custom", "type": "ss", "server": "1.2.3.4", "port": 8388,
+				"cipher": "chacha20-ietf-poly1305", "password": "pass",
+				"plugin": "obfs", "plugin-opts": map[string]any{"mode": "tls", "host": "example.com"},
+			},
+		},
+		{
+			name:       "vmess",
+			definition: "vmess, example.com, 443, username=uuid, tls=true, sni=sni.example.com, ws=true, ws-path=/ws, ws-headers=Host:cdn.example.com",
+			want: map[string]any{
+				"name": "vmess", "type": "vmess", "server": "example.com", "port": 443,
+				"uuid": "uuid", "alterId": 0, "cipher": "auto", "tls": true, "servername": "sni.example.com",
+				"network": "ws", "ws-opts": map[string]any{"path": "/ws", "headers": map[string]string{"Host": "cdn.example.com"}},
+			},
+		},
+		{
+			name:       "http",
+			definition: "https, example.com, 443, user, pass",
+			want: map[string]any{
+				"name": "http", "type": "http", "server": "example.com", "port": 443,
+				"tls": true, "username": "user", "password": "pass",
+			},
+		},
+		{
+			name:       "snell",
+			definition: "snell, 1.2.3.4, 6160, psk=key, version=4",
+			want: map[string]any{
+				"name": "snell", "type": "snell", "server": "1.2.3.4", "port": 6160, "psk": "key", "version": 4,
+			},
+		},
+		{name: "direct", definition: "direct"},
+		{name: "invalid port", definition: "ss, 1.2.3.4, abc", wantErr: true},
+		{name: "unsupported", definition: "external, 1.2.3.4, 1080", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertSurgeProxy(tt.name, tt.definition)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertSurgeProxy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertSurgeProxy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertQuantumultXServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxyType  string
+		definition string
+		want       map[string]any
+		wantErr    bool
+	}{
+		{
+			name:       "shadowsocks",
+			proxyType:  "shadowsocks",
+			definition: "1.2.3.4:8388, method=aes-128-gcm, password=pass, obfs=wss, obfs-host=example.com, obfs-uri=/ws, tag=ss",
+			want: map[string]any{
+				"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388,
+				"cipher": "aes-128-gcm", "password": "pass", "plugin": "v2ray-plugin",
+				"plugin-opts": map[string]any{"mode": "websocket", "host": "example.com", "path": "/ws", "tls": true},
+			},
+		},
+		{
+			name:       "vless",
+			proxyType:  "vless",
+			definition: "example.com:443, method=none, password=uuid, obfs=over-tls, obfs-host=sni.example.com, tag=vless",
+			want: map[string]any{
+				"name": "vless", "type": "vless", "server": "example.com", "port": 443,
+				"uuid": "uuid", "tls": true, "servername": "sni.example.com",
+			},
+		},
+		{
+			name:       "trojan",
+			proxyType:  "trojan",
+			definition: "example.com:443, password=pass, over-tls=true, tls-host=sni.example.com, tls-verification=false, tag=trojan",
+			want: map[string]any{
+				"name": "trojan", "type": "trojan", "server": "example.com", "port": 443,
+				"password": "pass", "sni": "sni.example.com", "skip-cert-verify": true,
+			},
+		},
+		{name: "missing tag", proxyType: "http", definition: "1.2.3.4:80", wantErr: true},
+		{name: "missing port", proxyType: "http", definition: "1.2.3.4, tag=http", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertQuantumultXServer(tt.proxyType, tt.definition)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertQuantumultXServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertQuantumultXServer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 转换为 Surge / Quantumult X 后再解析回来，应得到相同的节点
+func TestSurgeRoundTrip(t *testing.T) {
+	tests := []map[string]any{
+		{
+			"name": "ss", "type": "ss", "server": "1.2.3.4", "port": 8388, "udp": true,
+			"cipher": "aes-128-gcm", "password": "pass",
+			"plugin": "obfs", "plugin-opts": map[string]any{"mode": "http", "host": "example.com"},
+		},
+		{
+			"name": "trojan", "type": "trojan", "server": "example.com", "port": 443,
+			"password": "pass", "sni": "sni.example.com", "skip-cert-verify": true,
+		},
+		{
+			"name": "socks5", "type": "socks5", "server": "1.2.3.4", "port": 1080,
+			"tls": true, "username": "user", "password": "pass",
+		},
+	}
+	for _, config := range tests {
+		name := configString(config, "name")
+		t.Run("surge "+name, func(t *testing.T) {
+			line, err := clashToSurge(config, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxies, err := parseSurgeProxies([]byte("[Proxy]\n" + line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(proxies[0], config) {
+				t.Errorf("round trip of %q = %v, want %v", line, proxies[0], config)
+			}
+		})
+		t.Run("qx "+name, func(t *testing.T) {
+			line, err := clashToQuantumultX(config, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxies, err := parseQuantumultXServers([]byte(line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(proxies[0], config) {
+				t.Errorf("round trip of %q = %v, want %v", line, proxies[0], config)
+			}
+		})
+	}
+}
+
+func TestWriteProxyLinesIndex(t *testing.T) {
+	renameTemplate = template.Must(template.New("rename").Parse("node-{{.Index}}"))
+	defer func() { renameTemplate = nil }()
+
+	proxies := map[string]CProxy{
+		"a": {SecretConfig: map[string]any{"name": "a", "type": "wireguard", "server": "1.2.3.4", "port": 51820}},
+		"b": {SecretConfig: map[string]any{"name": "b", "type": "http", "server": "1.2.3.4", "port": 80}},
+		"c": {SecretConfig: map[string]any{"name": "c", "type": "socks5", "server": "1.2.3.4", "port": 1080}},
+	}
+	results := []Result{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	path := filepath.Join(t.TempDir(), "surge.conf")
+	if err := writeProxyLines(path, "[Proxy]", results, proxies, clashToSurge); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[Proxy]",
+		"node-1 = http, 1.2.3.4, 80",
+		"node-2 = socks5, 1.2.3.4, 1080",
+	}
+	if got := strings.Split(strings.TrimSpace(string(buf)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("writeProxyLines() = %q, want %q", got, want)
+	}
+}
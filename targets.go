@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type testTarget struct {
+	Name string
+	URL  string
+}
+
+// TargetResult 是节点到某个测试目标的带宽和延迟
+type TargetResult struct {
+	Bandwidth float64
+	TTFB      time.Duration
+}
+
+// testTargets 由 -targets 解析得到，顺序与参数一致
+var testTargets []testTarget
+
+// parseTargets 解析 us=https://...,eu=https://... 格式的测试目标
+func parseTargets(s string) ([]testTarget, error) {
+	var targets []testTarget
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, targetURL, ok := strings.Cut(item, "=")
+		name, targetURL = strings.TrimSpace(name), strings.TrimSpace(targetURL)
+		if !ok || name == "" || targetURL == "" {
+			return nil, fmt.Errorf("target should be name=url: %s", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate target %s", name)
+		}
+		seen[name] = true
+		targets = append(targets, testTarget{Name: name, URL: targetURL})
+	}
+	return targets, nil
+}
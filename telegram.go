@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramAPI 为 Telegram Bot API 的地址
+var telegramAPI = "https://api.telegram.org"
+
+// TelegramBot 通过 Bot API 发送测试结果，并在 -watch 时接收 /test 命令
+type TelegramBot struct {
+	token  string
+	chat   string
+	client *http.Client
+}
+
+func newTelegramBot(token string, chat string) *TelegramBot {
+	// getUpdates 使用长轮询，超时需要长于轮询的等待时间
+	return &TelegramBot{token: token, chat: chat, client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (b *TelegramBot) call(method string, params url.Values, v any) error {
+	resp, err := b.client.PostForm(fmt.Sprintf("%s/bot%s/%s", telegramAPI, b.token, method), params)
+	if err != nil {
+		// 错误信息中的地址包含 token，不直接输出
+		return fmt.Errorf("%s failed: %w", method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	var body struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%s failed: %s", method, resp.Status)
+	}
+	if !body.OK {
+		return fmt.Errorf("%s failed: %s", method, body.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body.Result, v)
+}
+
+func unwrapURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
+
+func (b *TelegramBot) SendMessage(text string) error {
+	return b.call("sendMessage", url.Values{
+		"chat_id":                  {b.chat},
+		"text":                     {text},
+		"parse_mode":               {"HTML"},
+		"disable_web_page_preview": {"true"},
+	}, nil)
+}
+
+// SendSummary 发送可用节点数量和排名靠前的节点
+func (b *TelegramBot) SendSummary(results []Result) error {
+	top := topResults(results, notifyTopN)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>测速完成</b>：%d/%d 个节点可用\n", len(passedResults(results)), len(results))
+	for i, result := range top {
+		fmt.Fprintf(&sb, "\n%d. <code>%s</code>  %s  %s", i+1, html.EscapeString(formatName(result.Name)),
+			formatBandwidth(result.Bandwidth), formatMilliseconds(result.TTFB))
+	}
+	return b.SendMessage(sb.String())
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// fromChat 判断消息是否来自 -telegram-chat，chat 可以是数字 ID 或 @用户名
+func (u *telegramUpdate) fromChat(chat string) bool {
+	if u.Message == nil {
+		return false
+	}
+	if strings.HasPrefix(chat, "@") {
+		return strings.EqualFold(chat[1:], u.Message.Chat.Username)
+	}
+	return chat == strconv.FormatInt(u.Message.Chat.ID, 10)
+}
+
+// PollCommands 长轮询 getUpdates，收到 -telegram-chat 中的 /test 命令时向 trigger 发送信号，
+// 上一次测试还没有结束时忽略新的命令
+func (b *TelegramBot) PollCommands(trigger chan<- struct{}) {
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := b.call("getUpdates", url.Values{
+			"offset":          {strconv.FormatInt(offset, 10)},
+			"timeout":         {"30"},
+			"allowed_updates": {`["message"]`},
+		}, &updates)
+		if err != nil {
+			log.Warnln("failed to get telegram updates: %s", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if !update.fromChat(b.chat) {
+				continue
+			}
+			command, _, _ := strings.Cut(strings.TrimSpace(update.Message.Text), " ")
+			command, _, _ = strings.Cut(command, "@")
+			if command != "/test" {
+				continue
+			}
+			select {
+			case trigger <- struct{}{}:
+				_ = b.SendMessage("开始测试...")
+			default:
+				_ = b.SendMessage("正在测试中，请稍后")
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	C "github.com/Dreamacro/clash/constant"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 下载测试的分阶段超时，为 0 时连接和首字节超时使用 -timeout，不检测停滞
+var connectTimeout, ttfbTimeout, stallTimeout time.Duration
+
+// parseTimeouts 解析 -connect-timeout、-ttfb-timeout 和 -stall-timeout
+func parseTimeouts() error {
+	for _, item := range []struct {
+		value  string
+		target *time.Duration
+	}{
+		{*connectTimeoutConfig, &connectTimeout},
+		{*ttfbTimeoutConfig, &ttfbTimeout},
+		{*stallTimeoutConfig, &stallTimeout},
+	} {
+		if item.value == "" {
+			continue
+		}
+		d, err := parseDuration(item.value)
+		if err != nil {
+			return err
+		}
+		*item.target = d
+	}
+	return nil
+}
+
+// newDownloadClient 返回下载测试对象使用的 client：连接节点和等待响应头分别受 -connect-timeout 和 -ttfb-timeout 限制；
+// 指定 -stall-timeout 时不再限制整个下载的时长，改为在持续没有收到数据时中止
+func newDownloadClient(proxy C.Proxy, timeout time.Duration) *http.Client {
+	client := newProxyClient(proxy, timeout)
+	transport := client.Transport.(*http.Transport)
+	connect, ttfb := connectTimeout, ttfbTimeout
+	if stallTimeout > 0 {
+		client.Timeout = 0
+		// 不再限制整个下载的时长后，连接和等待响应头仍需要超时
+		if connect == 0 {
+			connect = timeout
+		}
+		if ttfb == 0 {
+			ttfb = timeout
+		}
+	}
+	if connect > 0 {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, connect)
+			defer cancel()
+			return dialProxy(ctx, proxy, addr)
+		}
+	}
+	if ttfb > 0 {
+		transport.ResponseHeaderTimeout = ttfb
+	}
+	return client
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nodeTrend 为一个节点在统计时间内的历史表现
+type nodeTrend struct {
+	Name      string
+	Tests     int
+	Passed    int
+	Bandwidth float64   // 成功测试的平均带宽
+	Change    float64   // 按天线性拟合的带宽在整个时间段内的变化比例
+	Daily     []float64 // 每天成功测试的平均带宽，没有数据的日期为 0
+	Hours     [24]hourStat
+	BestHour  int
+	WorstHour int
+}
+
+// hourStat 为一天中某个小时的统计，失败的测试按带宽 0 计入平均值
+type hourStat struct {
+	Tests     int
+	Passed    int
+	Bandwidth float64
+}
+
+func (t *nodeTrend) Uptime() float64 {
+	if t.Tests == 0 {
+		return 0
+	}
+	return float64(t.Passed) / float64(t.Tests)
+}
+
+// runTrend 实现 trend 子命令，根据 -history 记录的历史结果输出节点的可用率、带宽趋势和最好、最差的时段，返回进程退出码
+func runTrend(args []string) int {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	historyFile := fs.String("history", "history.jsonl", "history file written by -history")
+	node := fs.String("node", "", "only show this node, show hourly details when specified")
+	days := fs.Int("days", 30, "number of days to analyze")
+	trendOutput := fs.String("trend-output", "", "also write the report to -fn, only html is supported")
+	outputFile := fs.String("fn", "trend.html", "output file of -trend-output")
+	_ = fs.Parse(args)
+
+	if *trendOutput != "" && *trendOutput != "html" {
+		fmt.Fprintf(os.Stderr, "Unsupported trend output: %s\n", *trendOutput)
+		return 2
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -*days)
+	records, err := newHistory(*historyFile).Load(from, to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	trends := computeTrends(records, *node, from, *days)
+	if len(trends) == 0 {
+		fmt.Fprintf(os.Stderr, "No history found in the last %d days\n", *days)
+		return 1
+	}
+
+	printTrends(trends, *node != "")
+	if *trendOutput == "html" {
+		if err := os.WriteFile(*outputFile, []byte(trendHTML(trends, *days, *node != "")), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// computeTrends 按节点汇总历史记录，按可用率和平均带宽排序
+func computeTrends(records []historyRecord, node string, from time.Time, days int) []*nodeTrend {
+	byName := make(map[string]*nodeTrend)
+	dailySum := make(map[string][]float64)
+	dailyCount := make(map[string][]int)
+	for _, record := range records {
+		if node != "" && record.Name != node {
+			continue
+		}
+		trend, ok := byName[record.Name]
+		if !ok {
+			trend = &nodeTrend{Name: record.Name}
+			byName[record.Name] = trend
+			dailySum[record.Name] = make([]float64, days+1)
+			dailyCount[record.Name] = make([]int, days+1)
+		}
+		trend.Tests++
+		hour := &trend.Hours[record.Time.Local().Hour()]
+		hour.Tests++
+		if record.Bandwidth <= 0 {
+			continue
+		}
+		trend.Passed++
+		trend.Bandwidth += record.Bandwidth
+		hour.Passed++
+		hour.Bandwidth += record.Bandwidth
+		day := int(record.Time.Sub(from) / (24 * time.Hour))
+		if day >= 0 && day <= days {
+			dailySum[record.Name][day] += record.Bandwidth
+			dailyCount[record.Name][day]++
+		}
+	}
+
+	trends := make([]*nodeTrend, 0, len(byName))
+	for name, trend := range byName {
+		if trend.Passed > 0 {
+			trend.Bandwidth /= float64(trend.Passed)
+		}
+		for i := range trend.Hours {
+			if trend.Hours[i].Tests > 0 {
+				trend.Hours[i].Bandwidth /= float64(trend.Hours[i].Tests)
+			}
+		}
+		trend.BestHour, trend.WorstHour = bestWorstHours(trend.Hours)
+
+		trend.Daily = make([]float64, days+1)
+		var xs, ys []float64
+		for day, count := range dailyCount[name] {
+			if count == 0 {
+				continue
+			}
+			trend.Daily[day] = dailySum[name][day] / float64(count)
+			xs, ys = append(xs, float64(day)), append(ys, trend.Daily[day])
+		}
+		// 只有一天的数据时无法判断趋势
+		if slope, ok := linearSlope(xs, ys); ok && trend.Bandwidth > 0 {
+			trend.Change = slope * (xs[len(xs)-1] - xs[0]) / trend.Bandwidth
+		}
+		trends = append(trends, trend)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Uptime() != trends[j].Uptime() {
+			return trends[i].Uptime() > trends[j].Uptime()
+		}
+		return trends[i].Bandwidth > trends[j].Bandwidth
+	})
+	return trends
+}
+
+// bestWorstHours 返回平均带宽最高和最低的小时，没有测试的小时不参与比较
+func bestWorstHours(hours [24]hourStat) (int, int) {
+	best, worst := -1, -1
+	for i, hour := range hours {
+		if hour.Tests == 0 {
+			continue
+		}
+		if best < 0 || hour.Bandwidth > hours[best].Bandwidth {
+			best = i
+		}
+		if worst < 0 || hour.Bandwidth < hours[worst].Bandwidth {
+			worst = i
+		}
+	}
+	return best, worst
+}
+
+// linearSlope 返回最小二乘拟合的斜率，少于两个不同的点时返回 false
+func linearSlope(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 {
+		return 0, false
+	}
+	meanX, _ := meanStddev(xs)
+	meanY, _ := meanStddev(ys)
+	var num, den float64
+	for i := range xs {
+		num += (xs[i] - meanX) * (ys[i] - meanY)
+		den += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if den == 0 {
+		return 0, false
+	}
+	return num / den, true
+}
+
+func formatChange(change float64) string {
+	switch {
+	case change > 0.05:
+		return fmt.Sprintf("↑%.0f%%", change*100)
+	case change < -0.05:
+		return fmt.Sprintf("↓%.0f%%", -change*100)
+	}
+	return "→"
+}
+
+func formatHour(hour int) string {
+	if hour < 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%02d:00", hour)
+}
+
+func printTrends(trends []*nodeTrend, detail bool) {
+	fmt.Printf("%-42s\t%-8s\t%-8s\t%-12s\t%-8s\t%-8s\t%-8s\t%s\n", "节点", "测试次数", "可用率", "平均带宽", "趋势", "最好时段", "最差时段", "每日带宽")
+	for _, trend := range trends {
+		fmt.Printf("%-42s\t%-8d\t%-8s\t%-12s\t%-8s\t%-8s\t%-8s\t%s\n", formatName(trend.Name), trend.Tests,
+			fmt.Sprintf("%.1f%%", trend.Uptime()*100), formatBandwidth(trend.Bandwidth), formatChange(trend.Change),
+			formatHour(trend.BestHour), formatHour(trend.WorstHour), sparkline(trend.Daily))
+	}
+	if !detail {
+		return
+	}
+	for _, trend := range trends {
+		fmt.Printf("\n===%s 各时段表现===\n", formatName(trend.Name))
+		fmt.Printf("%-8s\t%-8s\t%-8s\t%-12s\n", "时段", "测试次数", "可用率", "平均带宽")
+		for i, hour := range trend.Hours {
+			if hour.Tests == 0 {
+				continue
+			}
+			fmt.Printf("%-8s\t%-8d\t%-8s\t%-12s\n", formatHour(i), hour.Tests,
+				fmt.Sprintf("%.1f%%", float64(hour.Passed)*100/float64(hour.Tests)), formatBandwidth(hour.Bandwidth))
+		}
+	}
+}
+
+// trendHTML 生成与终端输出内容相同的 html 报告
+func trendHTML(trends []*nodeTrend, days int, detail bool) string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>节点历史表现</title>\n")
+	sb.WriteString("<style>body{font-family:sans-serif}table{border-collapse:collapse;margin-bottom:1em}th,td{border:1px solid #ccc;padding:4px 8px}td.spark{font-family:monospace}</style>\n</head><body>\n")
+	fmt.Fprintf(&sb, "<h1>最近 %d 天的节点表现</h1>\n", days)
+	sb.WriteString("<table>\n<tr><th>节点</th><th>测试次数</th><th>可用率</th><th>平均带宽</th><th>趋势</th><th>最好时段</th><th>最差时段</th><th>每日带宽</th></tr>\n")
+	for _, trend := range trends {
+		fmt.Fprintf(&sb, "<tr><td>%s</td><td>%d</td><td>%.1f%%</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td class=\"spark\">%s</td></tr>\n",
+			html.EscapeString(trend.Name), trend.Tests, trend.Uptime()*100, formatBandwidth(trend.Bandwidth),
+			formatChange(trend.Change), formatHour(trend.BestHour), formatHour(trend.WorstHour), sparkline(trend.Daily))
+	}
+	sb.WriteString("</table>\n")
+	if detail {
+		for _, trend := range trends {
+			fmt.Fprintf(&sb, "<h2>%s 各时段表现</h2>\n<table>\n<tr><th>时段</th><th>测试次数</th><th>可用率</th><th>平均带宽</th></tr>\n", html.EscapeString(trend.Name))
+			for i, hour := range trend.Hours {
+				if hour.Tests == 0 {
+					continue
+				}
+				fmt.Fprintf(&sb, "<tr><td>%s</td><td>%d</td><td>%.1f%%</td><td>%s</td></tr>\n", formatHour(i), hour.Tests,
+					float64(hour.Passed)*100/float64(hour.Tests), formatBandwidth(hour.Bandwidth))
+			}
+			sb.WriteString("</table>\n")
+		}
+	}
+	sb.WriteString("</body></html>\n")
+	return sb.String()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	C "github.com/Dreamacro/clash/constant"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// stunServers 用于检测 UDP 转发，映射地址在两个服务器间一致时认为是 full-cone
+var stunServers = []string{"stun.l.google.com:19302", "stun.cloudflare.com:3478"}
+
+const stunMagicCookie = 0x2112A442
+
+// CheckUDP 通过节点的 UDP 转发向 STUN 服务器发送 binding 请求，返回 ok、blocked、full-cone 或 unsupported
+func CheckUDP(proxy C.Proxy, timeout time.Duration) string {
+	if !proxy.SupportUDP() {
+		return "unsupported"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var pc C.PacketConn
+	var mapped []netip.AddrPort
+	for _, server := range stunServers {
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			continue
+		}
+		u16Port, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			continue
+		}
+		ip, err := resolveTargetIP(ctx, host)
+		if err != nil {
+			continue
+		}
+		metadata := &C.Metadata{NetWork: C.UDP, Host: host, DstIP: ip, DstPort: uint16(u16Port)}
+		if pc == nil {
+			// 所有 STUN 服务器共用一个 PacketConn，才能比较不同目标的映射地址
+			if pc, err = proxy.ListenPacketContext(ctx, metadata); err != nil {
+				return "blocked"
+			}
+			defer pc.Close()
+		}
+		addr, err := stunBinding(ctx, pc, net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(u16Port))))
+		if err != nil {
+			continue
+		}
+		mapped = append(mapped, addr)
+	}
+
+	switch {
+	case len(mapped) == 0:
+		return "blocked"
+	case len(mapped) > 1 && mapped[0] == mapped[1]:
+		return "full-cone"
+	default:
+		return "ok"
+	}
+}
+
+// stunBinding 发送 STUN binding 请求并返回 XOR-MAPPED-ADDRESS 或 MAPPED-ADDRESS
+func stunBinding(ctx context.Context, pc net.PacketConn, server net.Addr) (netip.AddrPort, error) {
+	request := make([]byte, 20)
+	binary.BigEndian.PutUint16(request[0:], 0x0001)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return netip.AddrPort{}, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = pc.SetReadDeadline(deadline)
+	}
+
+	buf := make([]byte, 1500)
+	// UDP 可能丢包，超时前重发几次请求
+	for i := 0; i < 3; i++ {
+		if _, err := pc.WriteTo(request, server); err != nil {
+			return netip.AddrPort{}, err
+		}
+		_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			if n < 20 || !bytes.Equal(buf[8:20], request[8:20]) {
+				continue
+			}
+			return parseSTUNResponse(buf[:n])
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return netip.AddrPort{}, fmt.Errorf("stun binding to %s timeout", server)
+}
+
+func parseSTUNResponse(msg []byte) (netip.AddrPort, error) {
+	if binary.BigEndian.Uint16(msg[0:]) != 0x0101 {
+		return netip.AddrPort{}, fmt.Errorf("unexpected stun message type %#04x", binary.BigEndian.Uint16(msg[0:]))
+	}
+	var mapped netip.AddrPort
+	attrs := msg[20:]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+attrLen {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+		switch attrType {
+		case 0x0020: // XOR-MAPPED-ADDRESS
+			if addr, ok := stunAddress(value, msg[4:20]); ok {
+				return addr, nil
+			}
+		case 0x0001: // MAPPED-ADDRESS
+			if addr, ok := stunAddress(value, nil); ok {
+				mapped = addr
+			}
+		}
+		// 属性按 4 字节对齐
+		attrs = attrs[4+(attrLen+3)&^3:]
+	}
+	if mapped.IsValid() {
+		return mapped, nil
+	}
+	return netip.AddrPort{}, fmt.Errorf("no mapped address in stun response")
+}
+
+// stunAddress 解析地址属性，xor 不为 nil 时按 magic cookie 和事务 ID 解码
+func stunAddress(value []byte, xor []byte) (netip.AddrPort, bool) {
+	if len(value) < 8 {
+		return netip.AddrPort{}, false
+	}
+	port := binary.BigEndian.Uint16(value[2:])
+	ip := append([]byte(nil), value[4:]...)
+	if xor != nil {
+		port ^= stunMagicCookie >> 16
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	return netip.AddrPortFrom(addr.Unmap(), port), true
+}
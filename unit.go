@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+)
+
+// 带宽的显示单位：auto 按大小在 B/s、KB/s、MB/s、GB/s 间切换，mb 固定为 MB/s，mbps 固定为 Mbps；
+// bandwidthBase 为 1024（默认）或 -si 时的 1000
+var (
+	bandwidthUnit = "auto"
+	bandwidthBase = 1024.0
+)
+
+func setupBandwidthUnit(unit string, si bool) error {
+	switch unit {
+	case "auto", "mb", "mbps":
+	default:
+		return fmt.Errorf("unsupported unit %s", unit)
+	}
+	bandwidthUnit = unit
+	if si {
+		bandwidthBase = 1000
+	}
+	return nil
+}
+
+// toMegaUnit 将字节每秒换算为 MB/s，-unit mbps 时换算为 Mbps
+func toMegaUnit(v float64) float64 {
+	v = v / bandwidthBase / bandwidthBase
+	if bandwidthUnit == "mbps" {
+		v *= 8
+	}
+	return v
+}
+
+// csvBandwidthHeader 返回 csv 中带宽列的表头，csv 的单位固定，auto 时为 MB/s
+func csvBandwidthHeader(name string) string {
+	if bandwidthUnit == "mbps" {
+		return name + " (Mbps)"
+	}
+	return name + " (MB/s)"
+}
+
+func csvBandwidth(v float64) string {
+	return fmt.Sprintf("%.2f", toMegaUnit(v))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Dreamacro/clash/adapter"
+	"github.com/Dreamacro/clash/adapter/provider"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+type validateIssue struct {
+	Line    int
+	Entry   string
+	Message string
+}
+
+// runValidate 实现 validate 子命令，逐个解析节点和 Provider 并报告全部错误，返回进程退出码
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("c", "", "configuration file path, also support http(s) url, glob pattern, directory and - for stdin")
+	fs.Var(&configHeaders, "config-header", "extra header for fetching remote configs, can be repeated")
+	fs.StringVar(configUA, "config-ua", "", "user agent for fetching remote configs")
+	fs.StringVar(fetchProxy, "fetch-proxy", "", "proxy for fetching remote configs")
+	fs.StringVar(configCacheDir, "config-cache", "", "directory to cache remote configs")
+	fs.BoolVar(offline, "offline", false, "use cached remote configs without fetching")
+	_ = fs.Parse(args)
+
+	if *configPath == "" {
+		fmt.Fprintln(os.Stderr, "Please specify the configuration file")
+		return 2
+	}
+
+	failed := false
+	for _, path := range expandConfigPaths(*configPath) {
+		body, err := readConfig(path)
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, err)
+			failed = true
+			continue
+		}
+		total, issues, err := validateConfig(body)
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, err)
+			failed = true
+			continue
+		}
+		for _, issue := range issues {
+			// 转换自其他格式的节点没有行号
+			if issue.Line > 0 {
+				fmt.Printf("%s:%d: %s: %s\n", path, issue.Line, issue.Entry, issue.Message)
+			} else {
+				fmt.Printf("%s: %s: %s\n", path, issue.Entry, issue.Message)
+			}
+		}
+		fmt.Printf("%s: %d entries, %d errors\n", path, total, len(issues))
+		if len(issues) > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// validateConfig 检查配置中的节点和 Provider，base64 订阅、分享链接、sing-box、Surge 和 Quantumult X 格式
+// 与测试时一样先转换为 Clash 节点配置
+func validateConfig(buf []byte) (int, []validateIssue, error) {
+	buf, converted, isConverted, err := convertConfig(buf)
+	if err != nil {
+		return 0, nil, err
+	}
+	if isShareLinks(buf) {
+		return validateShareLinks(buf)
+	}
+	if isConverted {
+		var issues []validateIssue
+		names := make(map[string]string)
+		for i, config := range converted {
+			if issue := validateProxy(config, fmt.Sprintf("proxy %d", i), 0, names); issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+		return len(converted), issues, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(buf, &root); err != nil {
+		return 0, nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return 0, nil, fmt.Errorf("config is not a yaml mapping")
+	}
+
+	var issues []validateIssue
+	total := 0
+	names := make(map[string]string)
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "proxies":
+			for j, node := range value.Content {
+				total++
+				entry := fmt.Sprintf("proxy %d", j)
+				var config map[string]any
+				if err := node.Decode(&config); err != nil {
+					issues = append(issues, validateIssue{node.Line, entry, err.Error()})
+					continue
+				}
+				if issue := validateProxy(config, entry, node.Line, names); issue != nil {
+					issues = append(issues, *issue)
+				}
+			}
+		case "proxy-providers":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				total++
+				nameNode, node := value.Content[j], value.Content[j+1]
+				entry := fmt.Sprintf("provider %q", nameNode.Value)
+				if nameNode.Value == provider.ReservedName {
+					issues = append(issues, validateIssue{nameNode.Line, entry, "reserved provider name"})
+					continue
+				}
+				var config map[string]any
+				if err := node.Decode(&config); err != nil {
+					issues = append(issues, validateIssue{nameNode.Line, entry, err.Error()})
+					continue
+				}
+				pd, err := provider.ParseProxyProvider(nameNode.Value, config)
+				if err != nil {
+					issues = append(issues, validateIssue{nameNode.Line, entry, err.Error()})
+					continue
+				}
+				if err := pd.Initial(); err != nil {
+					issues = append(issues, validateIssue{nameNode.Line, entry, err.Error()})
+				}
+			}
+		}
+	}
+	return total, issues, nil
+}
+
+// validateProxy 检查单个节点配置，names 记录已出现的节点名及其位置，用于发现重名的节点
+func validateProxy(config map[string]any, entry string, line int, names map[string]string) *validateIssue {
+	position := entry
+	if line > 0 {
+		position = fmt.Sprintf("line %d", line)
+	}
+	if name, ok := config["name"].(string); ok {
+		entry = fmt.Sprintf("proxy %q", name)
+		if first, exist := names[name]; exist {
+			return &validateIssue{line, entry, fmt.Sprintf("duplicate name, first defined at %s", first)}
+		}
+		names[name] = position
+	}
+	if _, err := adapter.ParseProxy(config); err != nil {
+		return &validateIssue{line, entry, err.Error()}
+	}
+	return nil
+}
+
+// validateShareLinks 逐行转换分享链接，转换时会被忽略的无效链接也报告出来；
+// 同名的链接转换时会自动加上序号，因此不检查重名
+func validateShareLinks(buf []byte) (int, []validateIssue, error) {
+	var issues []validateIssue
+	total := 0
+	for i, line := range strings.Split(string(buf), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		total++
+		entry := fmt.Sprintf("link %d", total)
+		proxies, err := parseShareLinks([]byte(line))
+		if err != nil {
+			issues = append(issues, validateIssue{i + 1, entry, err.Error()})
+			continue
+		}
+		if issue := validateProxy(proxies[0], entry, i+1, make(map[string]string)); issue != nil {
+			issues = append(issues, *issue)
+		}
+	}
+	return total, issues, nil
+}
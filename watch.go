@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/Dreamacro/clash/log"
+	"os"
+	"strings"
+	"time"
+)
+
+// localCheckInterval 为检查本地配置文件修改时间的间隔，只调用 stat，不读取文件内容
+const localCheckInterval = 2 * time.Second
+
+// configDigest 计算全部配置内容的摘要
+func configDigest(configs []configFile) [sha256.Size]byte {
+	h := sha256.New()
+	for _, config := range configs {
+		h.Write([]byte(config.Path))
+		h.Write(config.Body)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// localConfigState 返回本地配置文件的路径、修改时间和大小，文件修改、新增或删除时结果随之变化
+func localConfigState(configPaths string) string {
+	var b strings.Builder
+	for _, configPath := range expandConfigPaths(configPaths) {
+		if configPath == "-" || strings.HasPrefix(configPath, "http") {
+			continue
+		}
+		info, err := os.Stat(configPath)
+		if err != nil {
+			fmt.Fprintf(&b, "%s|missing\n", configPath)
+			continue
+		}
+		fmt.Fprintf(&b, "%s|%d|%d\n", configPath, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String()
+}
+
+// reloadConfigs 重新读取配置，fetchRemote 为 false 时订阅地址沿用 previous 中下载到的内容，只重新读取本地文件
+func reloadConfigs(configPaths string, previous []configFile, fetchRemote bool) []configFile {
+	if fetchRemote {
+		return readConfigs(configPaths)
+	}
+	bodies := make(map[string][]byte, len(previous))
+	for _, config := range previous {
+		bodies[config.Path] = config.Body
+	}
+	var configs []configFile
+	for _, configPath := range expandConfigPaths(configPaths) {
+		if body, ok := bodies[configPath]; ok && strings.HasPrefix(configPath, "http") {
+			configs = append(configs, configFile{Path: configPath, Body: body})
+			continue
+		}
+		body, err := readConfig(configPath)
+		if err != nil {
+			log.Warnln("failed to read config: %s", err)
+			continue
+		}
+		configs = append(configs, configFile{Path: configPath, Body: body})
+	}
+	return configs
+}
+
+// watchConfigs 先执行一次测试，之后每隔 localCheckInterval 检查本地文件的修改时间，每隔 interval 重新下载订阅，
+// 内容变化或收到 trigger 时用读取到的配置重新测试；每次重新展开路径以发现目录中新增的文件。
+// 第一次测试出错时退出，之后的错误只记录，不中断监视
+func watchConfigs(configPaths string, interval time.Duration, trigger <-chan struct{}, run func([]configFile) error) {
+	configs := readConfigs(configPaths)
+	digest := configDigest(configs)
+	state := localConfigState(configPaths)
+	if err := run(configs); err != nil {
+		log.Fatalln("%s", err)
+	}
+	localTicker := time.NewTicker(localCheckInterval)
+	defer localTicker.Stop()
+	remoteTicker := time.NewTicker(interval)
+	defer remoteTicker.Stop()
+	fmt.Printf("\n等待配置变化，订阅每 %s 检查一次...\n", interval)
+	for {
+		var reloaded []configFile
+		manual := false
+		select {
+		case <-localTicker.C:
+			current := localConfigState(configPaths)
+			if current == state {
+				continue
+			}
+			state = current
+			reloaded = reloadConfigs(configPaths, configs, false)
+		case <-remoteTicker.C:
+			if !strings.Contains(configPaths, "http") {
+				continue
+			}
+			reloaded = reloadConfigs(configPaths, configs, true)
+		case <-trigger:
+			manual = true
+			configs = readConfigs(configPaths)
+			digest = configDigest(configs)
+			fmt.Printf("\n收到测试命令，重新测试 (%s)\n\n", time.Now().Format("2006-01-02 15:04:05"))
+			// 未指定 -cache 时缓存只用于配置变化后的增量测试，手动触发时全部重新测试
+			if resultCache != nil && resultCache.path == "" {
+				resultCache.Entries = make(map[string]cacheEntry)
+			}
+		}
+		if !manual {
+			current := configDigest(reloaded)
+			if current == digest {
+				continue
+			}
+			configs, digest = reloaded, current
+			fmt.Printf("\n配置已变化，重新测试 (%s)\n\n", time.Now().Format("2006-01-02 15:04:05"))
+		}
+		if err := run(configs); err != nil {
+			log.Warnln("speed test failed: %s", err)
+		}
+		fmt.Printf("\n等待配置变化，订阅每 %s 检查一次...\n", interval)
+	}
+}